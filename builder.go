@@ -1,38 +1,78 @@
 package config
 
 import (
-	"bufio"
-	"bytes"
+	"context"
 	"fmt"
-	"os"
-	"reflect"
-	"sort"
 	"strings"
-
-	"github.com/pkg/errors"
 )
 
 const (
-	defaultStructDelimiter = "."
-	defaultSliceDelimiter  = " "
+	defaultStructDelimiter      = "."
+	defaultSliceDelimiter       = " "
+	defaultMapPairDelimiter     = ","
+	defaultMapKeyValueDelimiter = ":"
 )
 
+// Builder reads config values from sources, such as the environment and files, and decodes them into structs.
+// Create one with New.
 type Builder struct {
-	structDelimiter string
-	sliceDelimiter  string
-	configMap       map[string]string
-	failedFields    []string
+	structDelimiter      string
+	sliceDelimiter       string
+	mapPairDelimiter     string
+	mapKeyValueDelimiter string
+	envPrefix            string
+	configMap            map[string]string
+	typedMap             map[string]any
+	origins              map[string][]Origin
+	precedence           map[string]int
+	keyNormalizer        KeyNormalizer
+	collisions           map[string][]string
+	lists                map[string]Origin
+	secretKeys           map[string]bool
+	decoded              bool
+	decoders             decoders
+	loaders              []func(*Builder)
+	files                []string
+	failedFields         []FieldError
+	opts                 []Option
 }
 
 // FromEnv reads environment variables and adds them to the config map.
+// When a prefix is set with WithEnvPrefix, only the variables with the prefix are read.
+// References such as ${DB_HOST} in their values are expanded, but "$$" is kept as written rather than
+// collapsed to "$", so that deployed values such as PASS=pa$$word reach the config unchanged.
 func (b *Builder) FromEnv() *Builder {
-	mergeMaps(b.configMap, keyValsToMap(os.Environ()))
+	b.record(func(rebuilt *Builder) { rebuilt.FromEnv() })
+
+	return b.load(context.Background(), EnvSource{Prefix: b.envPrefix, Delimiter: b.structDelimiter}, true)
+}
+
+// scopeEnv returns the environment variables that start with prefix, with the prefix stripped and
+// '_' replaced by the struct delimiter.
+//
+// Example:
+//
+//	scopeEnv(map[string]string{"MYAPP_DB_HOST": "localhost", "PATH": "/bin"}, "MYAPP_", ".")
+//	// Output: map[DB.HOST:localhost]
+func scopeEnv(values map[string]string, prefix string, delimiter string) map[string]string {
+	scoped := make(map[string]string)
+
+	for key, val := range values {
+		name, found := strings.CutPrefix(key, prefix)
+		if !found || name == "" {
+			continue
+		}
+
+		scoped[strings.ReplaceAll(name, "_", delimiter)] = val
+	}
 
-	return b
+	return scoped
 }
 
 // FromFile reads a file and adds its contents to the config map.
 func (b *Builder) FromFile(file string) *Builder {
+	b.record(func(rebuilt *Builder) { rebuilt.FromFile(file) }, file)
+
 	if IsLocal() {
 		return b.appendFile(file, true)
 	}
@@ -40,7 +80,16 @@ func (b *Builder) FromFile(file string) *Builder {
 	return b.appendFile(file, false)
 }
 
+// FromOptionalFile reads a file and adds its contents to the config map, if the file exists.
+// Unlike FromFile, a missing file is never reported, but any other read error is.
+func (b *Builder) FromOptionalFile(file string) *Builder {
+	b.record(func(rebuilt *Builder) { rebuilt.FromOptionalFile(file) }, file)
+
+	return b.load(context.Background(), FileSource{Path: file, Optional: true}, true)
+}
+
 // MapTo accepts a struct pointer and populates it with the current config state.
+// Values can be read from secret files, such as DB_PASSWORD_FILE, as described in secrets.go.
 func (b *Builder) MapTo(target any) error {
 	return b.decode(target, "")
 }
@@ -51,77 +100,161 @@ func (b *Builder) Sub(target any, prefix string) error {
 	return b.decode(target, prefix+b.structDelimiter)
 }
 
-// decode reads the config map and populates the target struct with the values.
-// It returns an error if any fields failed to convert.
-func (b *Builder) decode(target any, prefix string) error {
-	structPtr := reflect.ValueOf(target)
+// record keeps a source added to the builder, along with the files it reads, so that rebuild can read
+// the source again and a Watcher can poll the files.
+func (b *Builder) record(load func(*Builder), files ...string) {
+	b.loaders = append(b.loaders, load)
+	b.files = append(b.files, files...)
+}
 
-	if structPtr.Kind() != reflect.Ptr || structPtr.Elem().Kind() != reflect.Struct {
-		panic("config: failed to decode. target must be a struct pointer")
+// rebuild returns a new builder with the same options as b, which reads the sources of b again, in the same order.
+func (b *Builder) rebuild() *Builder {
+	rebuilt := New(b.opts...)
+
+	for _, load := range b.loaders {
+		load(rebuilt)
 	}
 
-	m := make(map[string]reflect.Value)
-	mapKeysToFields(structPtr, m, prefix, b.structDelimiter)
+	return rebuilt
+}
+
+// mergeValues merges values into the config map, overwriting existing keys, unless their value comes from
+// a source of higher precedence, as set by WithPrecedence.
+// Keys are normalized first, as set by WithKeyNormalizer. Keys of values that collapse to the same key
+// are reported by decode, if a field uses that key.
+// Typed values, keyed like values, are kept alongside their string form; keys that are overwritten
+// without a typed value lose the typed value they had.
+// The origin of the values, with the line of each key if known, is recorded for Explain and for errors.
+func (b *Builder) mergeValues(values map[string]string, typed map[string]any, origin Origin, lines map[string]int) {
+	normalized := b.normalizeValues(values, typed, lines)
+	accepted := make(map[string]string, len(normalized.values))
+
+	for key, val := range normalized.values {
+		if !b.outranked(key, origin.Source) {
+			accepted[key] = val
+		}
+	}
 
-	for key, fieldPtr := range m {
-		stringValue, ok := b.configMap[key]
+	mergeMaps(b.configMap, accepted)
+	b.recordOrigin(normalized.values, origin, normalized.lines)
 
-		if !ok {
-			continue
+	if b.typedMap == nil {
+		b.typedMap = make(map[string]any, len(normalized.typed))
+	}
+
+	if b.collisions == nil {
+		b.collisions = make(map[string][]string)
+	}
+
+	for key := range accepted {
+		delete(b.typedMap, key)
+		delete(b.collisions, key)
+
+		if keys, ok := normalized.collisions[key]; ok {
+			b.collisions[key] = keys
 		}
 
-		switch fieldPtr.Kind() {
-		case reflect.Slice:
-			for _, i := range convertAndSetSlice(fieldPtr, stringToSlice(stringValue, b.sliceDelimiter)) {
-				b.failedFields = append(b.failedFields, fmt.Sprintf("%s[%d]", key, i))
-			}
-		default:
-			if !convertAndSetValue(fieldPtr, stringValue) {
-				b.failedFields = append(b.failedFields, key)
-			}
+		if val, ok := normalized.typed[key]; ok {
+			b.typedMap[key] = val
 		}
 	}
+}
 
-	sort.Strings(b.failedFields) // sort for deterministic output
-
-	if len(b.failedFields) > 0 {
-		return errors.Errorf("config: the following fields had errors: %s", strings.Join(b.failedFields, ", "))
+// replaceLists makes each list of a structured source, keyed as in lists, replace the list of the same key set
+// by the sources it takes precedence over, rather than merge with it element by element, so that a shorter list
+// does not keep the trailing elements of a longer one. The elements of the replaced list are removed from the
+// config map, except those set by sources of higher precedence, such as SERVERS_0_HOST in the environment.
+// If a source of higher precedence set the whole list, its list is kept, and the elements of the list of the
+// source are removed from values instead.
+func (b *Builder) replaceLists(lists map[string]bool, values map[string]string, origin Origin) {
+	if b.lists == nil {
+		b.lists = make(map[string]Origin, len(lists))
 	}
 
-	return nil
-}
+	for list := range lists {
+		list = b.normalizeKey(list)
 
-// appendFile reads a file and adds its contents to the config map.
-// If includeErr is true, it will also add any errors to the failedFields slice.
-func (b *Builder) appendFile(file string, includeErr bool) *Builder {
-	content, err := os.ReadFile(file)
+		if owner, ok := b.lists[list]; ok && b.rank(owner.Source) > b.rank(origin.Source) {
+			for key := range values {
+				if b.isListElement(b.normalizeKey(key), list) {
+					delete(values, key)
+				}
+			}
 
-	if includeErr && err != nil {
-		b.failedFields = append(b.failedFields, fmt.Sprintf("file[%v]: read - %s", file, err.Error()))
-	}
+			continue
+		}
 
-	scanner := bufio.NewScanner(bytes.NewReader(content))
+		for key := range b.configMap {
+			if b.isListElement(key, list) && !b.outranked(key, origin.Source) {
+				b.removeKey(key)
+			}
+		}
+
+		b.lists[list] = origin
+	}
+}
 
-	var scannedStrings []string
-	for scanner.Scan() {
-		scannedStrings = append(scannedStrings, scanner.Text())
+// isListElement returns true if key is the key of list itself, as set for an empty list, or the key of one of its
+// elements or of the fields of its elements, such as servers.0 or servers.0.host for servers.
+func (b *Builder) isListElement(key string, list string) bool {
+	if key == list {
+		return true
 	}
 
-	if includeErr && scanner.Err() != nil {
-		b.failedFields = append(b.failedFields, fmt.Sprintf("file[%v]: scan - %s", file, scanner.Err().Error()))
+	rest, found := strings.CutPrefix(key, list+b.keyDelimiter())
+	if !found {
+		return false
 	}
 
-	mergeMaps(b.configMap, keyValsToMap(scannedStrings))
+	index, _, _ := strings.Cut(rest, b.keyDelimiter())
+	_, ok := parseIndex(index)
 
-	return b
+	return ok
 }
 
-// newBuilder creates a new Builder with the provided options.
-func newBuilder(opts ...Option) *Builder {
+// removeKey removes a key from the config map, along with its typed value, collisions and origins.
+func (b *Builder) removeKey(key string) {
+	delete(b.configMap, key)
+	delete(b.typedMap, key)
+	delete(b.collisions, key)
+	delete(b.origins, key)
+}
+
+// addFailure records a failure of a source, which is reported by every decode.
+func (b *Builder) addFailure(fieldErr FieldError) {
+	b.failedFields = append(b.failedFields, fieldErr)
+}
+
+// appendFile reads a dotenv file and adds its contents to the config map.
+// If includeErr is true, it will also report read errors.
+// Syntax errors are always reported, positioned as file:line.
+func (b *Builder) appendFile(file string, includeErr bool) *Builder {
+	return b.load(context.Background(), FileSource{Path: file}, includeErr)
+}
+
+// New creates a new Builder with the provided options. Builders must be created with New,
+// since the zero value has no config map to add sources to.
+//
+// Example:
+//
+//	builder := config.New(config.WithEnvPrefix("MYAPP_")).FromYAML("config.yaml").FromEnv()
+//	if err := builder.MapTo(&cfg); err != nil {
+//	  log.Fatal(err)
+//	}
+func New(opts ...Option) *Builder {
 	builder := &Builder{
-		structDelimiter: defaultStructDelimiter,
-		sliceDelimiter:  defaultSliceDelimiter,
-		configMap:       make(map[string]string),
+		structDelimiter:      defaultStructDelimiter,
+		sliceDelimiter:       defaultSliceDelimiter,
+		mapPairDelimiter:     defaultMapPairDelimiter,
+		mapKeyValueDelimiter: defaultMapKeyValueDelimiter,
+		configMap:            make(map[string]string),
+		typedMap:             make(map[string]any),
+		origins:              make(map[string][]Origin),
+		precedence:           make(map[string]int),
+		keyNormalizer:        LowerCaseKeys,
+		collisions:           make(map[string][]string),
+		lists:                make(map[string]Origin),
+		opts:                 opts,
 	}
 
 	for _, opt := range opts {
@@ -158,3 +291,78 @@ func WithSliceDelimiter(delimiter string) Option {
 		builder.sliceDelimiter = delimiter
 	}
 }
+
+// WithPrecedence ranks kinds of sources, such as SourceDotenv and SourceEnv, from lowest to highest precedence.
+// A key set by a source of higher precedence is never overwritten by a source of lower precedence,
+// whatever the order the sources are added in. Sources of the same kind, and sources that are not listed,
+// which rank below the listed ones, overwrite each other in the order they are added.
+//
+// Example:
+//
+//	// The environment wins over dotenv files, and .env.local wins over .env.
+//	config.New(config.WithPrecedence(config.SourceDotenv, config.SourceEnv)).
+//	  FromEnv().FromFile(".env").FromOptionalFile(".env.local")
+func WithPrecedence(sources ...string) Option {
+	return func(builder *Builder) {
+		precedence := make(map[string]int, len(sources))
+
+		for i, source := range sources {
+			if _, ok := precedence[source]; ok {
+				panic(fmt.Sprintf("config: source %q is listed more than once in the precedence", source))
+			}
+
+			precedence[source] = i + 1
+		}
+
+		builder.precedence = precedence
+	}
+}
+
+// WithEnvPrefix scopes FromEnv to the environment variables that start with prefix, such as "MYAPP_",
+// so that unrelated variables, such as PATH or the settings of other apps, are left out.
+// The prefix is stripped and '_' is replaced by the struct delimiter, so that MYAPP_DB_HOST sets db.host.
+// The prefix is matched as written.
+func WithEnvPrefix(prefix string) Option {
+	return func(builder *Builder) {
+		prefix = strings.TrimSpace(prefix)
+
+		if prefix == "" {
+			panic("config: env prefix cannot be empty")
+		}
+
+		builder.envPrefix = prefix
+	}
+}
+
+// WithKeyNormalizer sets how keys are normalized before they are stored and matched against struct fields.
+// The default, LowerCaseKeys, matches keys regardless of case. EnvStyleKeys also matches env-style keys,
+// such as DATABASE_HOST, to nested fields.
+func WithKeyNormalizer(normalizer KeyNormalizer) Option {
+	return func(builder *Builder) {
+		if normalizer == nil {
+			panic("config: key normalizer cannot be nil")
+		}
+
+		builder.keyNormalizer = normalizer
+	}
+}
+
+// WithMapDelimiters sets the delimiters used to parse inline map values, such as "team:x,env:y".
+// The pair delimiter separates entries, and the key-value delimiter separates a key from its value.
+func WithMapDelimiters(pairDelimiter string, keyValueDelimiter string) Option {
+	return func(builder *Builder) {
+		pairDelimiter = strings.TrimSpace(pairDelimiter)
+		keyValueDelimiter = strings.TrimSpace(keyValueDelimiter)
+
+		if pairDelimiter == "" || keyValueDelimiter == "" {
+			panic("config: map delimiters cannot be empty")
+		}
+
+		if pairDelimiter == keyValueDelimiter {
+			panic("config: map delimiters must be different")
+		}
+
+		builder.mapPairDelimiter = pairDelimiter
+		builder.mapKeyValueDelimiter = keyValueDelimiter
+	}
+}
@@ -0,0 +1,50 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/vnworkday/config"
+)
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	type target struct {
+		Server struct {
+			Port  int      `config:"port"`
+			Hosts []string `config:"hosts"`
+		} `config:"server"`
+		Name string `config:"name"`
+	}
+
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(file, []byte("server:\n  port: 8080\n  hosts: [a, b]\nname: file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	builder := config.New(config.WithPrecedence(config.SourceYAML, "overrides")).
+		From(config.NewMapSource("overrides", map[string]string{"name": "override"})).
+		FromYAML(file)
+
+	var got target
+	if err := builder.MapTo(&got); err != nil {
+		t.Fatalf("MapTo()\nexpected:\t<nil>\nactual:\t\t%v", err)
+	}
+
+	var want target
+	want.Server.Port = 8080
+	want.Server.Hosts = []string{"a", "b"}
+	want.Name = "override"
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("MapTo()\nexpected:\t%+v\nactual:\t\t%+v", want, got)
+	}
+
+	explanation, ok := builder.Explain("name")
+	if wantOrigin := (config.Origin{Source: "overrides"}); !ok || explanation.Origin != wantOrigin {
+		t.Errorf("Explain()\nexpected:\t%v\nactual:\t\t%v", wantOrigin, explanation.Origin)
+	}
+}
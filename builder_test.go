@@ -1,123 +1,14 @@
 package config
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 )
 
-func TestDecode(t *testing.T) {
-	t.Parallel()
-
-	tests := []struct {
-		name    string
-		config  map[string]string
-		target  any
-		prefix  string
-		wantOut any
-		wantErr bool
-	}{
-		{
-			name: "When config map matches struct fields then it should populate the struct",
-			config: map[string]string{
-				"Field1": "value1",
-				"Field2": "value2",
-			},
-			target: &struct {
-				Field1 string
-				Field2 string
-			}{},
-			prefix: "",
-			wantOut: &struct {
-				Field1 string
-				Field2 string
-			}{
-				Field1: "value1",
-				Field2: "value2",
-			},
-			wantErr: false,
-		},
-		{
-			name: "When config map has extra fields then it should ignore them",
-			config: map[string]string{
-				"Field1": "value1",
-				"Field2": "value2",
-				"Field3": "value3",
-			},
-			target: &struct {
-				Field1 string
-				Field2 string
-			}{},
-			prefix: "",
-			wantOut: &struct {
-				Field1 string
-				Field2 string
-			}{
-				Field1: "value1",
-				Field2: "value2",
-			},
-			wantErr: false,
-		},
-		{
-			name: "When config map is missing fields then it should leave them as zero values",
-			config: map[string]string{
-				"Field1": "value1",
-			},
-			target: &struct {
-				Field1 string
-				Field2 string
-			}{},
-			prefix: "",
-			wantOut: &struct {
-				Field1 string
-				Field2 string
-			}{
-				Field1: "value1",
-			},
-			wantErr: false,
-		},
-		{
-			name: "When config map has invalid values then it should return an error",
-			config: map[string]string{
-				"Field1": "value1",
-				"Field2": "not a number",
-			},
-			target: &struct {
-				Field1 string
-				Field2 int
-			}{},
-			prefix: "",
-			wantOut: &struct {
-				Field1 string
-				Field2 int
-			}{
-				Field1: "value1",
-				Field2: 0,
-			},
-			wantErr: true,
-		},
-	}
-
-	for _, test := range tests {
-		t.Run(test.name, func(t *testing.T) {
-			t.Parallel()
-
-			b := &Builder{
-				configMap: test.config,
-			}
-
-			err := b.decode(test.target, test.prefix)
-			if (err != nil) != test.wantErr {
-				t.Errorf(failTestMessage("decode", test.wantErr, err))
-			}
-
-			if !test.wantErr && !reflect.DeepEqual(test.target, test.wantOut) {
-				t.Errorf(failTestMessage("decode", test.wantOut, test.target))
-			}
-		})
-	}
-}
-
 func TestAppendFile(t *testing.T) {
 	t.Parallel()
 
@@ -179,6 +70,92 @@ func TestAppendFile(t *testing.T) {
 	}
 }
 
+func TestFromOptionalFile(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		layers  []string
+		missing bool
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name:    "When file does not exist then it should be skipped without an error",
+			missing: true,
+			want:    map[string]string{},
+			wantErr: false,
+		},
+		{
+			name:    "When files are layered then later files should take precedence",
+			layers:  []string{"key1=base\nkey2=base", "key2=profile"},
+			want:    map[string]string{"key1": "base", "key2": "profile"},
+			wantErr: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			builder := New()
+			dir := t.TempDir()
+
+			if test.missing {
+				builder.FromOptionalFile(filepath.Join(dir, ".env.missing"))
+			}
+
+			for i, content := range test.layers {
+				file := filepath.Join(dir, fmt.Sprintf(".env.%d", i))
+
+				if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+					t.Fatal(err)
+				}
+
+				builder.FromOptionalFile(file)
+			}
+
+			if (len(builder.failedFields) > 0) != test.wantErr {
+				t.Errorf(failTestMessage("FromOptionalFile", test.wantErr, builder.failedFields))
+			}
+
+			if !reflect.DeepEqual(builder.configMap, test.want) {
+				t.Errorf(failTestMessage("FromOptionalFile", test.want, builder.configMap))
+			}
+		})
+	}
+}
+
+func TestMergeValues(t *testing.T) {
+	t.Parallel()
+
+	builder := New()
+
+	builder.mergeValues(
+		map[string]string{"port": "8080", "debug": "true"},
+		map[string]any{"port": 8080, "debug": true},
+		Origin{Source: SourceJSON, File: "config.json"},
+		nil,
+	)
+	builder.mergeValues(map[string]string{"port": "9090"}, nil, Origin{Source: SourceEnv}, nil)
+
+	wantConfig := map[string]string{"port": "9090", "debug": "true"}
+	wantTyped := map[string]any{"debug": true}
+
+	if !reflect.DeepEqual(builder.configMap, wantConfig) {
+		t.Errorf(failTestMessage("mergeValues", wantConfig, builder.configMap))
+	}
+
+	if !reflect.DeepEqual(builder.typedMap, wantTyped) {
+		t.Errorf(failTestMessage("mergeValues", wantTyped, builder.typedMap))
+	}
+
+	wantOrigins := map[string]Origin{"port": {Source: SourceEnv}, "debug": {Source: SourceJSON, File: "config.json"}}
+	if !reflect.DeepEqual(builder.Origins(), wantOrigins) {
+		t.Errorf(failTestMessage("mergeValues", wantOrigins, builder.Origins()))
+	}
+}
+
 func TestNewBuilder(t *testing.T) {
 	t.Parallel()
 
@@ -233,15 +210,276 @@ func TestNewBuilder(t *testing.T) {
 				opts = append(opts, WithSliceDelimiter(test.sliceDelimiter))
 			}
 
-			builder := newBuilder(opts...)
+			builder := New(opts...)
 
 			if builder.structDelimiter != test.wantStructDelim {
-				t.Errorf("newBuilder() structDelimiter = %v, want %v", builder.structDelimiter, test.wantStructDelim)
+				t.Errorf("New() structDelimiter = %v, want %v", builder.structDelimiter, test.wantStructDelim)
 			}
 
 			if builder.sliceDelimiter != test.wantSliceDelim {
-				t.Errorf("newBuilder() sliceDelimiter = %v, want %v", builder.sliceDelimiter, test.wantSliceDelim)
+				t.Errorf("New() sliceDelimiter = %v, want %v", builder.sliceDelimiter, test.wantSliceDelim)
 			}
 		})
 	}
 }
+
+func TestWithMapDelimiters(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name              string
+		pairDelimiter     string
+		keyValueDelimiter string
+		wantPanic         bool
+	}{
+		{
+			name:              "When delimiters are valid then they should be set",
+			pairDelimiter:     ";",
+			keyValueDelimiter: "=",
+			wantPanic:         false,
+		},
+		{
+			name:              "When a delimiter is empty then it should panic",
+			pairDelimiter:     " ",
+			keyValueDelimiter: "=",
+			wantPanic:         true,
+		},
+		{
+			name:              "When delimiters are the same then it should panic",
+			pairDelimiter:     ",",
+			keyValueDelimiter: ",",
+			wantPanic:         true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			defer func() {
+				if r := recover(); (r != nil) != test.wantPanic {
+					t.Errorf(failTestMessage("WithMapDelimiters", test.wantPanic, r))
+				}
+			}()
+
+			builder := New(WithMapDelimiters(test.pairDelimiter, test.keyValueDelimiter))
+
+			if builder.mapPairDelimiter != test.pairDelimiter || builder.mapKeyValueDelimiter != test.keyValueDelimiter {
+				t.Errorf(failTestMessage("WithMapDelimiters", test.pairDelimiter+test.keyValueDelimiter,
+					builder.mapPairDelimiter+builder.mapKeyValueDelimiter))
+			}
+		})
+	}
+}
+
+func TestWithPrecedence(t *testing.T) {
+	t.Parallel()
+
+	type merge struct {
+		values map[string]string
+		typed  map[string]any
+		origin Origin
+	}
+
+	env := merge{values: map[string]string{"port": "9090"}, origin: Origin{Source: SourceEnv}}
+	dotenv := merge{values: map[string]string{"port": "8080"}, origin: Origin{Source: SourceDotenv, File: ".env"}}
+	dotenvLocal := merge{
+		values: map[string]string{"port": "7070"},
+		origin: Origin{Source: SourceDotenv, File: ".env.local"},
+	}
+	json := merge{
+		values: map[string]string{"port": "6060"},
+		typed:  map[string]any{"port": int64(6060)},
+		origin: Origin{Source: SourceJSON, File: "config.json"},
+	}
+
+	tests := []struct {
+		name         string
+		precedence   []string
+		merges       []merge
+		wantPort     string
+		wantTyped    map[string]any
+		wantShadowed []Origin
+	}{
+		{
+			name:         "When no precedence is set then the last source should win",
+			precedence:   nil,
+			merges:       []merge{env, dotenv},
+			wantPort:     "8080",
+			wantTyped:    map[string]any{},
+			wantShadowed: []Origin{env.origin},
+		},
+		{
+			name:         "When env ranks above dotenv then env should win even if it is added first",
+			precedence:   []string{SourceDotenv, SourceEnv},
+			merges:       []merge{env, dotenv, dotenvLocal},
+			wantPort:     "9090",
+			wantTyped:    map[string]any{},
+			wantShadowed: []Origin{dotenv.origin, dotenvLocal.origin},
+		},
+		{
+			name:         "When sources have the same rank then the last one should win",
+			precedence:   []string{SourceDotenv, SourceEnv},
+			merges:       []merge{dotenv, dotenvLocal},
+			wantPort:     "7070",
+			wantTyped:    map[string]any{},
+			wantShadowed: []Origin{dotenv.origin},
+		},
+		{
+			name:         "When an outranked source is added then the typed value of the winner should be kept",
+			precedence:   []string{SourceEnv, SourceJSON},
+			merges:       []merge{json, env},
+			wantPort:     "6060",
+			wantTyped:    map[string]any{"port": int64(6060)},
+			wantShadowed: []Origin{env.origin},
+		},
+		{
+			name:         "When a source is not ranked then it should rank below the listed ones",
+			precedence:   []string{SourceEnv},
+			merges:       []merge{env, json},
+			wantPort:     "9090",
+			wantTyped:    map[string]any{},
+			wantShadowed: []Origin{json.origin},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			builder := New(WithPrecedence(test.precedence...))
+
+			for _, m := range test.merges {
+				builder.mergeValues(m.values, m.typed, m.origin, nil)
+			}
+
+			if got := builder.configMap["port"]; got != test.wantPort {
+				t.Errorf(failTestMessage("mergeValues", test.wantPort, got))
+			}
+
+			if !reflect.DeepEqual(builder.typedMap, test.wantTyped) {
+				t.Errorf(failTestMessage("mergeValues", test.wantTyped, builder.typedMap))
+			}
+
+			explanation, _ := builder.Explain("port")
+			if !reflect.DeepEqual(explanation.Shadowed, test.wantShadowed) {
+				t.Errorf(failTestMessage("Explain", test.wantShadowed, explanation.Shadowed))
+			}
+		})
+	}
+}
+
+func TestWithPrecedencePanics(t *testing.T) {
+	t.Parallel()
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf(failTestMessage("WithPrecedence", "panic", r))
+		}
+	}()
+
+	New(WithPrecedence(SourceEnv, SourceDotenv, SourceEnv))
+}
+
+func TestScopeEnv(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		values    map[string]string
+		prefix    string
+		delimiter string
+		want      map[string]string
+	}{
+		{
+			name:      "When variables have the prefix then it should be stripped and '_' translated",
+			values:    map[string]string{"MYAPP_DB_HOST": "localhost", "MYAPP_PORT": "8080"},
+			prefix:    "MYAPP_",
+			delimiter: ".",
+			want:      map[string]string{"DB.HOST": "localhost", "PORT": "8080"},
+		},
+		{
+			name:      "When variables do not have the prefix then they should be left out",
+			values:    map[string]string{"PATH": "/bin", "OTHERAPP_PORT": "80", "myapp_port": "90", "MYAPP_": "empty"},
+			prefix:    "MYAPP_",
+			delimiter: ".",
+			want:      map[string]string{},
+		},
+		{
+			name:      "When the struct delimiter is '_' then keys should be kept as written",
+			values:    map[string]string{"MYAPP_DB_HOST": "localhost"},
+			prefix:    "MYAPP_",
+			delimiter: "_",
+			want:      map[string]string{"DB_HOST": "localhost"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := scopeEnv(test.values, test.prefix, test.delimiter); !reflect.DeepEqual(got, test.want) {
+				t.Errorf(failTestMessage("scopeEnv", test.want, got))
+			}
+		})
+	}
+}
+
+func TestFromEnvWithPrefix(t *testing.T) {
+	t.Setenv("CONFIG_TEST_DB_HOST", "localhost")
+	t.Setenv("CONFIG_TEST_DB_PORT", "5432")
+	t.Setenv("DB_HOST", "unrelated")
+
+	type target struct {
+		DB struct {
+			Host string `config:"host"`
+			Port int    `config:"port"`
+		} `config:"db"`
+	}
+
+	var got target
+
+	if err := New(WithEnvPrefix("CONFIG_TEST_")).FromEnv().MapTo(&got); err != nil {
+		t.Fatal(err)
+	}
+
+	if got.DB.Host != "localhost" || got.DB.Port != 5432 {
+		t.Errorf(failTestMessage("FromEnv", "localhost:5432", got))
+	}
+}
+
+func TestWithEnvPrefixPanics(t *testing.T) {
+	t.Parallel()
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf(failTestMessage("WithEnvPrefix", "panic", r))
+		}
+	}()
+
+	New(WithEnvPrefix(" "))
+}
+
+func TestRebuild(t *testing.T) {
+	t.Parallel()
+
+	file := filepath.Join(t.TempDir(), ".env")
+	writeFile(t, file, "DB_HOST=localhost\n")
+
+	builder := New(WithStructDelimiter("_")).
+		FromFile(file).
+		FromJSONReader(strings.NewReader(`{"db": {"port": 5432}}`))
+
+	writeFile(t, file, "DB_HOST=example.com\n")
+
+	rebuilt := builder.rebuild()
+
+	want := map[string]string{"db_host": "example.com", "db_port": "5432"}
+	if !reflect.DeepEqual(rebuilt.configMap, want) {
+		t.Errorf(failTestMessage("rebuild", want, rebuilt.configMap))
+	}
+
+	if !reflect.DeepEqual(rebuilt.files, []string{file}) {
+		t.Errorf(failTestMessage("rebuild", []string{file}, rebuilt.files))
+	}
+}
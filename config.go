@@ -3,16 +3,37 @@ package config
 import (
 	"flag"
 	"os"
+	"sync"
+	"time"
 )
 
-const configFile = ".env"
+const (
+	configFile          = ".env"
+	configFileLocalPart = "local"
+)
 
 const (
 	profileEnvVar  = "profile"
 	profileDefault = "local"
 )
 
-// LoadConfig reads environment variables and adds them to the config map.
+// LoadConfig reads environment variables and config files, and populates the struct with their values.
+//
+// The following files are read, in order of increasing precedence:
+//   - .env: the base file, required when the profile is "local".
+//   - .env.<profile>: profile-specific values, e.g. .env.staging or .env.prod. Optional.
+//   - .env.<profile>.local: developer overrides that should not be committed. Optional.
+//
+// When the profile is "local", the chain is .env followed by .env.local.
+//
+// Environment variables take precedence over all of these files, so that a value set by the deployment
+// always wins over a value committed to a file.
+//
+// References such as ${DB_HOST} are expanded in the values of both, and "$$" escapes a literal '$' in
+// the files. Values from environment variables keep "$$" as written, e.g. PASS=pa$$word stays pa$$word.
+//
+// To read the config from several goroutines while it changes at runtime, use LoadStore or WatchConfig,
+// which load the same sources into a Store.
 //
 // Parameters:
 //   - in: A pointer to struct to populate with the config values loaded from the environment and the config file.
@@ -23,10 +44,7 @@ const (
 func LoadConfig[T any](in *T) (*T, error) {
 	loadProfile()
 
-	err := newBuilder().
-		FromEnv().
-		FromFile(configFile).
-		MapTo(in)
+	err := loadBuilder(configFile, GetProfile()).MapTo(in)
 	if err != nil {
 		return nil, err
 	}
@@ -34,6 +52,43 @@ func LoadConfig[T any](in *T) (*T, error) {
 	return in, nil
 }
 
+// LoadStore loads the config the same way LoadConfig does, into a new Store.
+// Call Reload on the store, with a builder from the same sources, to update it later,
+// or use WatchConfig to reload it whenever the files change.
+func LoadStore[T any]() (*Store[T], error) {
+	loadProfile()
+
+	store := NewStore[T](nil)
+
+	if _, err := store.Reload(loadBuilder(configFile, GetProfile())); err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+// WatchConfig loads the config the same way LoadConfig does, and reloads it every time one of the files
+// changes, as checked every interval. The current value is held by the Store of the returned Watcher.
+func WatchConfig[T any](interval time.Duration) (*Watcher[T], error) {
+	loadProfile()
+
+	return Watch[T](loadBuilder(configFile, GetProfile()), interval)
+}
+
+// loadBuilder returns a builder holding the environment and the chain of dotenv files for the profile,
+// with the environment taking precedence over the files.
+func loadBuilder(base string, profile string) *Builder {
+	builder := New(WithPrecedence(SourceDotenv, SourceEnv)).
+		FromEnv().
+		FromFile(base)
+
+	for _, file := range profileFiles(base, profile) {
+		builder.FromOptionalFile(file)
+	}
+
+	return builder
+}
+
 // IsLocal returns true if the profile is set to "local".
 func IsLocal() bool {
 	profile := GetProfile()
@@ -52,13 +107,51 @@ func GetProfile() string {
 	return profile
 }
 
+// profileFiles returns the optional files layered on top of the base file for the given profile,
+// in order of increasing precedence.
+//
+// Example:
+//
+//	profileFiles(".env", "staging") // Output: []string{".env.staging", ".env.staging.local"}
+//	profileFiles(".env", "local")   // Output: []string{".env.local"}
+func profileFiles(base string, profile string) []string {
+	if profile == "" || profile == configFileLocalPart {
+		return []string{base + "." + configFileLocalPart}
+	}
+
+	return []string{
+		base + "." + profile,
+		base + "." + profile + "." + configFileLocalPart,
+	}
+}
+
+var defineProfileFlag sync.Once
+
 // LoadProfile reads the profile from the command line arguments and sets it as an environment variable.
+// The flag is defined on the first call only, so that the config can be loaded more than once.
+// The environment variable is only set when the flag is passed, so that a profile set in the environment
+// is not replaced by the default of the flag.
 func loadProfile() {
-	var profile string
+	defineProfileFlag.Do(func() {
+		flag.String(profileEnvVar, profileDefault, "Profile to use for configuration")
+	})
 
-	flag.StringVar(&profile, profileEnvVar, profileDefault, "Profile to use for configuration")
 	flag.Parse()
 
+	passed := false
+
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == profileEnvVar {
+			passed = true
+		}
+	})
+
+	if !passed {
+		return
+	}
+
+	profile := flag.Lookup(profileEnvVar).Value.String()
+
 	if profile == "" {
 		profile = profileDefault
 	}
@@ -1,6 +1,12 @@
 package config
 
-import "testing"
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
 
 func Test_shouldPanic(t *testing.T) {
 	t.Parallel()
@@ -48,3 +54,114 @@ func Test_shouldPanic(t *testing.T) {
 		})
 	}
 }
+
+func TestProfileFiles(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		base    string
+		profile string
+		want    []string
+	}{
+		{
+			name:    "When profile is local then only the local override should be layered",
+			base:    ".env",
+			profile: "local",
+			want:    []string{".env.local"},
+		},
+		{
+			name:    "When profile is empty then it should be treated as local",
+			base:    ".env",
+			profile: "",
+			want:    []string{".env.local"},
+		},
+		{
+			name:    "When profile is set then the profile file and its local override should be layered",
+			base:    ".env",
+			profile: "staging",
+			want:    []string{".env.staging", ".env.staging.local"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := profileFiles(test.base, test.profile); !reflect.DeepEqual(got, test.want) {
+				t.Errorf(failTestMessage("profileFiles", test.want, got))
+			}
+		})
+	}
+}
+
+func TestLoadBuilder(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	base := filepath.Join(dir, ".env")
+	files := map[string]string{
+		base:                    "PATH=/from/file\nCONFIG_TEST_NAME=base\nCONFIG_TEST_LEVEL=info\n",
+		base + ".staging":       "CONFIG_TEST_NAME=staging\n",
+		base + ".staging.local": "CONFIG_TEST_NAME=local\n",
+	}
+
+	for file, content := range files {
+		if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	builder := loadBuilder(base, "staging")
+
+	want := map[string]string{
+		"path":              strings.TrimSpace(os.Getenv("PATH")),
+		"config_test_name":  "local",
+		"config_test_level": "info",
+	}
+
+	for key, wantValue := range want {
+		if got := builder.configMap[key]; got != wantValue {
+			t.Errorf(failTestMessage("loadBuilder", wantValue, got))
+		}
+	}
+}
+
+func TestLoadProfile(t *testing.T) {
+	tests := []struct {
+		name  string
+		env   string
+		isSet bool
+		want  string
+	}{
+		{
+			name:  "When the profile is set in the environment then the flag should not replace it",
+			env:   "staging",
+			isSet: true,
+			want:  "staging",
+		},
+		{
+			name:  "When the profile is not set then the default should be used",
+			isSet: false,
+			want:  profileDefault,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv(profileEnvVar, test.env)
+
+			if !test.isSet {
+				if err := os.Unsetenv(profileEnvVar); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			loadProfile()
+
+			if got := GetProfile(); got != test.want {
+				t.Errorf(failTestMessage("GetProfile", test.want, got))
+			}
+		})
+	}
+}
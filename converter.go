@@ -1,37 +1,54 @@
 package config
 
 import (
+	"encoding"
+	"encoding/json"
+	"flag"
+	"net"
 	"net/url"
+	"os"
 	"reflect"
 	"strconv"
+	"strings"
 	"time"
+
+	"github.com/pkg/errors"
 )
 
+// sliceElementError is the error of a slice element that failed to convert.
+type sliceElementError struct {
+	index int
+	value string
+	err   error
+}
+
 // convertAndSetSlice converts a slice of strings to a value of the type that the slice holds,
-// and appends it to the slice. It returns a slice of indices that failed to convert.
+// and appends it to the slice. It returns the elements that failed to convert.
 // Supported types:
 //   - int, uint, float variants
 //   - bool, string
 //   - time.Duration
+//   - time.Time, in RFC 3339 format
 //   - *url.URL
+//   - any other type supported by convertAndSetValue
 //
 // Parameters:
 //   - slicePtr - A reflect.Value that points to the slice that will be set.
 //   - values - A slice of strings that will be converted and set on the slice.
 //
 // Returns:
-// A slice of indices that failed to convert.
-func convertAndSetSlice(slicePtr reflect.Value, values []string) []int {
+// A slice of the elements that failed to convert, with their index and error.
+func convertAndSetSlice(d decoders, slicePtr reflect.Value, values []string) []sliceElementError {
 	sliceVal := slicePtr.Elem()
 	elemType := sliceVal.Type().Elem()
 
-	var failedIndices []int
+	var failures []sliceElementError
 
 	for i, s := range values {
 		elemPtr := reflect.New(elemType)
 
-		if success := convertAndSetValue(elemPtr, s); !success {
-			failedIndices = append(failedIndices, i)
+		if err := convertAndSetValue(d, elemPtr, s); err != nil {
+			failures = append(failures, sliceElementError{index: i, value: s, err: err})
 		} else {
 			sliceVal.Set(reflect.Append(sliceVal, elemPtr.Elem()))
 		}
@@ -42,24 +59,33 @@ func convertAndSetSlice(slicePtr reflect.Value, values []string) []int {
 		sliceVal.Set(reflect.MakeSlice(sliceVal.Type(), 0, 0))
 	}
 
-	return failedIndices
+	return failures
 }
 
 // convertAndSetValue converts a string to a value of the type that the reflect.Value holds,
-// and sets it on the reflect.Value. It returns true if the conversion was successful.
+// and sets it on the reflect.Value. It returns the parse error if the conversion failed.
 // Supported types:
 //   - int, uint, float variants
 //   - bool, string
 //   - time.Duration
+//   - time.Time, in RFC 3339 format, or in the layout of the `layout` tag of the field
+//   - *time.Location, by IANA name such as Europe/Paris, or UTC and Local
 //   - *url.URL
+//   - net.IP, net.IPNet in CIDR notation, netip.Addr and netip.Prefix
+//   - *regexp.Regexp, *big.Int and *big.Float
+//   - os.FileMode, in octal such as 0644
+//   - types implementing encoding.TextUnmarshaler or flag.Value on their pointer, e.g. slog.Level
+//   - types with a decoder registered with WithDecoder
+//   - pointers to any of the above
+//   - Secret of any of the above
 //
 // Parameters:
 //   - settable - A reflect.Value that will be set with the converted value.
 //   - str - The string that will be converted and set on the settable.
 //
 // Returns:
-// A boolean indicating if the conversion was successful.
-func convertAndSetValue(settable reflect.Value, str string) bool {
+// An error if the string could not be converted, or if the type is not supported.
+func convertAndSetValue(d decoders, settable reflect.Value, str string) error {
 	var settableValue reflect.Value
 	if settable.Kind() == reflect.Ptr || settable.Kind() == reflect.Interface {
 		settableValue = settable.Elem()
@@ -67,10 +93,21 @@ func convertAndSetValue(settable reflect.Value, str string) bool {
 		settableValue = settable
 	}
 
+	if inner, ok := secretInner(settableValue); ok {
+		return convertAndSetValue(d, inner, str)
+	}
+
+	if decode, ok := d[settableValue.Type()]; ok {
+		return convertAndSetDecoded(decode, settableValue, str)
+	}
+
+	if settableValue.CanAddr() && isTextType(settableValue.Type()) {
+		return convertAndSetText(settableValue, str)
+	}
+
 	switch settableValue.Kind() {
 	case reflect.Pointer:
-		// Only URL is supported as a pointer type.
-		return convertAndSetURL(settableValue, str)
+		return convertAndSetPointer(d, settableValue, str)
 	case reflect.String:
 		return convertAndSetString(settableValue, str)
 	case reflect.Bool:
@@ -81,6 +118,75 @@ func convertAndSetValue(settable reflect.Value, str string) bool {
 		return convertAndSetUint(settableValue, str)
 	case reflect.Float32, reflect.Float64:
 		return convertAndSetFloat(settableValue, str)
+	case reflect.Struct:
+		if settableValue.Type() == ipNetType {
+			return convertAndSetIPNet(settableValue, str)
+		}
+
+		return convertAndSetTime(settableValue, str)
+	default:
+		return errors.Errorf("unsupported type %s", settableValue.Type())
+	}
+}
+
+// convertAndSetTyped sets a value that a structured source decoded with its type, such as a JSON number
+// or boolean, on the reflect.Value without going through its string form.
+// It returns false if the value does not fit the reflect.Value exactly, in which case the caller
+// should fall back to convertAndSetValue.
+// Supported types:
+//   - bool to bool
+//   - json.Number and int64 to int, uint, float variants, except time.Duration
+//   - float64 to float variants
+//   - time.Time to time.Time, even with a decoder of time.Time, such as the one of a `layout` tag,
+//     since a datetime that a source decoded has no text form of its own to decode
+//   - any of the above to a Secret of the type
+//
+// Parameters:
+//   - settable - A reflect.Value that will be set with the value.
+//   - val - The typed value that will be set on the settable.
+//
+// Returns:
+// A boolean indicating if the value was set.
+func convertAndSetTyped(d decoders, settable reflect.Value, val any) bool {
+	var settableValue reflect.Value
+	if settable.Kind() == reflect.Ptr || settable.Kind() == reflect.Interface {
+		settableValue = settable.Elem()
+	} else {
+		settableValue = settable
+	}
+
+	if inner, ok := secretInner(settableValue); ok {
+		return convertAndSetTyped(d, inner, val)
+	}
+
+	// Registered decoders convert the string form, even of values that would fit, except datetimes.
+	if _, ok := d[settableValue.Type()]; ok && settableValue.Type() != timeType {
+		return false
+	}
+
+	switch typedVal := val.(type) {
+	case bool:
+		if settableValue.Kind() != reflect.Bool {
+			return false
+		}
+
+		settableValue.SetBool(typedVal)
+
+		return true
+	case json.Number:
+		return setNumber(settableValue, typedVal)
+	case int64:
+		return setInt(settableValue, typedVal)
+	case float64:
+		return setFloat(settableValue, typedVal)
+	case time.Time:
+		if settableValue.Type() != timeType {
+			return false
+		}
+
+		settableValue.Set(reflect.ValueOf(typedVal))
+
+		return true
 	default:
 		return false
 	}
@@ -88,72 +194,250 @@ func convertAndSetValue(settable reflect.Value, str string) bool {
 
 // The following functions are helper functions used by convertAndSetValue to convert and set specific types.
 
-func convertAndSetURL(settableValue reflect.Value, str string) bool {
+func convertAndSetText(settableValue reflect.Value, str string) error {
+	switch target := settableValue.Addr().Interface().(type) {
+	case encoding.TextUnmarshaler:
+		return errors.WithStack(target.UnmarshalText([]byte(str)))
+	case flag.Value:
+		return errors.WithStack(target.Set(str))
+	default:
+		return errors.Errorf("unsupported type %s", settableValue.Type())
+	}
+}
+
+// convertAndSetPointer sets a pointer to a new value converted from the string, leaving it untouched on error.
+func convertAndSetPointer(d decoders, settableValue reflect.Value, str string) error {
+	switch settableValue.Type() {
+	case urlPtrType:
+		return convertAndSetURL(settableValue, str)
+	case locationPtrType:
+		return convertAndSetLocation(settableValue, str)
+	}
+
+	elemPtr := reflect.New(settableValue.Type().Elem())
+
+	if err := convertAndSetValue(d, elemPtr, str); err != nil {
+		return err
+	}
+
+	settableValue.Set(elemPtr)
+
+	return nil
+}
+
+func convertAndSetURL(settableValue reflect.Value, str string) error {
 	urlVal, err := url.Parse(str)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	settableValue.Set(reflect.ValueOf(urlVal))
+
+	return nil
+}
+
+func convertAndSetLocation(settableValue reflect.Value, str string) error {
+	loc, err := time.LoadLocation(str)
+	if err != nil {
+		return errors.WithStack(err)
+	}
 
-	if err == nil {
-		settableValue.Set(reflect.ValueOf(urlVal))
+	settableValue.Set(reflect.ValueOf(loc))
+
+	return nil
+}
+
+// convertAndSetIPNet sets the network of an address in CIDR notation, e.g. 10.0.0.0/8 for 10.1.2.3/8.
+func convertAndSetIPNet(settableValue reflect.Value, str string) error {
+	_, ipNet, err := net.ParseCIDR(str)
+	if err != nil {
+		return errors.WithStack(err)
 	}
 
-	return err == nil
+	settableValue.Set(reflect.ValueOf(*ipNet))
+
+	return nil
 }
 
-func convertAndSetString(settableValue reflect.Value, str string) bool {
+func convertAndSetTime(settableValue reflect.Value, str string) error {
+	if settableValue.Type() != timeType {
+		return errors.Errorf("unsupported type %s", settableValue.Type())
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, str)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	settableValue.Set(reflect.ValueOf(t))
+
+	return nil
+}
+
+func convertAndSetString(settableValue reflect.Value, str string) error {
 	settableValue.SetString(str)
 
-	return true
+	return nil
 }
 
-func convertAndSetBool(settableValue reflect.Value, str string) bool {
+func convertAndSetBool(settableValue reflect.Value, str string) error {
 	boolVal, err := strconv.ParseBool(str)
-
-	if err == nil {
-		settableValue.SetBool(boolVal)
+	if err != nil {
+		return errors.WithStack(err)
 	}
 
-	return err == nil
+	settableValue.SetBool(boolVal)
+
+	return nil
 }
 
-func convertAndSetInt(settableValue reflect.Value, str string) bool {
-	if settableValue.Type().PkgPath() == "time" && settableValue.Type().Name() == "Duration" {
+func convertAndSetInt(settableValue reflect.Value, str string) error {
+	if isDuration(settableValue.Type()) {
 		return convertAndSetDuration(settableValue, str)
 	}
 
 	intVal, err := strconv.ParseInt(str, 10, settableValue.Type().Bits())
-
-	if err == nil {
-		settableValue.SetInt(intVal)
+	if err != nil {
+		return errors.WithStack(err)
 	}
 
-	return err == nil
+	settableValue.SetInt(intVal)
+
+	return nil
 }
 
-func convertAndSetDuration(settableValue reflect.Value, str string) bool {
+func convertAndSetDuration(settableValue reflect.Value, str string) error {
 	d, err := time.ParseDuration(str)
-
-	if err == nil {
-		settableValue.SetInt(int64(d))
+	if err != nil {
+		return errors.WithStack(err)
 	}
 
-	return err == nil
+	settableValue.SetInt(int64(d))
+
+	return nil
 }
 
-func convertAndSetUint(settableValue reflect.Value, str string) bool {
+func convertAndSetUint(settableValue reflect.Value, str string) error {
+	if settableValue.Type() == fileModeType {
+		return convertAndSetFileMode(settableValue, str)
+	}
+
 	uintVal, err := strconv.ParseUint(str, 10, settableValue.Type().Bits())
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	settableValue.SetUint(uintVal)
+
+	return nil
+}
 
-	if err == nil {
-		settableValue.SetUint(uintVal)
+// convertAndSetFileMode sets file permissions written in octal, with or without a leading 0 or 0o,
+// e.g. 0644, 644 or 0o644.
+func convertAndSetFileMode(settableValue reflect.Value, str string) error {
+	octal := strings.TrimPrefix(strings.TrimPrefix(str, "0o"), "0O")
+
+	mode, err := strconv.ParseUint(octal, 8, 32)
+	if err != nil {
+		return errors.WithStack(err)
 	}
 
-	return err == nil
+	settableValue.SetUint(mode)
+
+	return nil
 }
 
-func convertAndSetFloat(settableValue reflect.Value, str string) bool {
+func convertAndSetFloat(settableValue reflect.Value, str string) error {
 	floatVal, err := strconv.ParseFloat(str, settableValue.Type().Bits())
+	if err != nil {
+		return errors.WithStack(err)
+	}
 
-	if err == nil {
-		settableValue.SetFloat(floatVal)
+	settableValue.SetFloat(floatVal)
+
+	return nil
+}
+
+var (
+	timeType        = reflect.TypeOf(time.Time{})
+	urlPtrType      = reflect.TypeOf(&url.URL{})
+	locationPtrType = reflect.TypeOf(&time.Location{})
+	ipNetType       = reflect.TypeOf(net.IPNet{})
+	fileModeType    = reflect.TypeOf(os.FileMode(0))
+)
+
+var (
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	flagValueType       = reflect.TypeOf((*flag.Value)(nil)).Elem()
+)
+
+// isTextType returns true for types that parse their own text form, because a pointer to the type implements
+// encoding.TextUnmarshaler or flag.Value, such as slog.Level or netip.Addr. They are converted as a whole,
+// whatever their kind, so that net.IP is not taken for a slice. time.Time keeps its built-in conversion.
+func isTextType(t reflect.Type) bool {
+	if t == timeType {
+		return false
 	}
 
-	return err == nil
+	ptrType := reflect.PointerTo(t)
+
+	return ptrType.Implements(textUnmarshalerType) || ptrType.Implements(flagValueType)
+}
+
+func isDuration(t reflect.Type) bool {
+	return t.PkgPath() == "time" && t.Name() == "Duration"
+}
+
+// setNumber sets a JSON number on a numeric reflect.Value, as long as it fits without loss.
+func setNumber(settableValue reflect.Value, num json.Number) bool {
+	if intVal, err := num.Int64(); err == nil {
+		return setInt(settableValue, intVal)
+	}
+
+	if floatVal, err := num.Float64(); err == nil {
+		return setFloat(settableValue, floatVal)
+	}
+
+	return false
+}
+
+// setInt sets an integer on a numeric reflect.Value, as long as it fits without loss.
+func setInt(settableValue reflect.Value, intVal int64) bool {
+	switch settableValue.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if isDuration(settableValue.Type()) || settableValue.OverflowInt(intVal) {
+			return false
+		}
+
+		settableValue.SetInt(intVal)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		// File modes are converted from their octal string form, which a number in JSON cannot express.
+		if intVal < 0 || settableValue.Type() == fileModeType || settableValue.OverflowUint(uint64(intVal)) {
+			return false
+		}
+
+		settableValue.SetUint(uint64(intVal))
+	case reflect.Float32, reflect.Float64:
+		settableValue.SetFloat(float64(intVal))
+	default:
+		return false
+	}
+
+	return true
+}
+
+// setFloat sets a floating-point number on a float reflect.Value, as long as it does not overflow.
+func setFloat(settableValue reflect.Value, floatVal float64) bool {
+	switch settableValue.Kind() {
+	case reflect.Float32, reflect.Float64:
+		if settableValue.OverflowFloat(floatVal) {
+			return false
+		}
+
+		settableValue.SetFloat(floatVal)
+
+		return true
+	default:
+		return false
+	}
 }
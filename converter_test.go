@@ -1,19 +1,54 @@
 package config
 
 import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/netip"
 	"net/url"
+	"os"
 	"reflect"
+	"regexp"
 	"slices"
+	"strconv"
 	"testing"
 	"time"
 )
 
+// testLevel is a custom enum that implements flag.Value.
+type testLevel int
+
+func (l *testLevel) Set(s string) error {
+	levels := map[string]testLevel{"low": 1, "high": 2}
+
+	level, ok := levels[s]
+	if !ok {
+		return fmt.Errorf("unknown level %q", s)
+	}
+
+	*l = level
+
+	return nil
+}
+
+func (l *testLevel) String() string {
+	return strconv.Itoa(int(*l))
+}
+
 func toURL(s string) *url.URL {
 	u, _ := url.Parse(s)
 
 	return u
 }
 
+func mustParseCIDR(s string) *net.IPNet {
+	_, ipNet, _ := net.ParseCIDR(s)
+
+	return ipNet
+}
+
 func TestConvertAndSetSlice(t *testing.T) {
 	t.Parallel()
 
@@ -52,6 +87,34 @@ func TestConvertAndSetSlice(t *testing.T) {
 			want:         []int{123, 456},
 			wantFailures: []int{2},
 		},
+		{
+			name:         "WhenValuesAreTextUnmarshalers",
+			slicePtr:     reflect.ValueOf(new([]slog.Level)),
+			values:       []string{"debug", "loud", "WARN+2"},
+			want:         []slog.Level{slog.LevelDebug, slog.LevelWarn + 2},
+			wantFailures: []int{1},
+		},
+		{
+			name:         "WhenValuesAreFlagValues",
+			slicePtr:     reflect.ValueOf(new([]testLevel)),
+			values:       []string{"high", "low"},
+			want:         []testLevel{2, 1},
+			wantFailures: []int{},
+		},
+		{
+			name:         "WhenValuesAreIPNets",
+			slicePtr:     reflect.ValueOf(new([]net.IPNet)),
+			values:       []string{"10.0.0.0/8", "10.0.0.1", "fd00::/8"},
+			want:         []net.IPNet{*mustParseCIDR("10.0.0.0/8"), *mustParseCIDR("fd00::/8")},
+			wantFailures: []int{1},
+		},
+		{
+			name:         "WhenValuesAreFileModes",
+			slicePtr:     reflect.ValueOf(new([]os.FileMode)),
+			values:       []string{"0600", "rw", "755"},
+			want:         []os.FileMode{0o600, 0o755},
+			wantFailures: []int{1},
+		},
 		{
 			name:         "WhenValuesAreUnsupportedType",
 			slicePtr:     reflect.ValueOf(new([]complex128)),
@@ -65,7 +128,10 @@ func TestConvertAndSetSlice(t *testing.T) {
 		t.Run(test.name, func(t *testing.T) {
 			t.Parallel()
 
-			gotFailures := convertAndSetSlice(test.slicePtr, test.values)
+			gotFailures := []int{}
+			for _, failure := range convertAndSetSlice(nil, test.slicePtr, test.values) {
+				gotFailures = append(gotFailures, failure.index)
+			}
 
 			if !slices.Equal(test.wantFailures, gotFailures) {
 				t.Errorf("convertAndSetSlice() = %v, want %v", gotFailures, test.wantFailures)
@@ -137,6 +203,167 @@ func TestConvertAndSetValue(t *testing.T) {
 			want:     time.Hour,
 			wantOk:   true,
 		},
+		{
+			name:     "When value is time.Time",
+			settable: reflect.ValueOf(new(time.Time)),
+			str:      "1979-05-27T07:32:00Z",
+			want:     time.Date(1979, 5, 27, 7, 32, 0, 0, time.UTC),
+			wantOk:   true,
+		},
+		{
+			name:     "When value is an invalid time.Time",
+			settable: reflect.ValueOf(new(time.Time)),
+			str:      "27/05/1979",
+			want:     nil,
+			wantOk:   false,
+		},
+		{
+			name:     "When value is *time.Location",
+			settable: reflect.ValueOf(new(*time.Location)),
+			str:      "UTC",
+			want:     time.UTC,
+			wantOk:   true,
+		},
+		{
+			name:     "When value is an unknown *time.Location",
+			settable: reflect.ValueOf(new(*time.Location)),
+			str:      "Mars/Olympus_Mons",
+			want:     nil,
+			wantOk:   false,
+		},
+		{
+			name:     "When value is net.IPNet",
+			settable: reflect.ValueOf(new(net.IPNet)),
+			str:      "10.1.2.3/8",
+			want:     net.IPNet{IP: net.IPv4(10, 0, 0, 0).To4(), Mask: net.CIDRMask(8, 32)},
+			wantOk:   true,
+		},
+		{
+			name:     "When value is an invalid net.IPNet",
+			settable: reflect.ValueOf(new(net.IPNet)),
+			str:      "10.1.2.3",
+			want:     nil,
+			wantOk:   false,
+		},
+		{
+			name:     "When value is netip.Prefix",
+			settable: reflect.ValueOf(new(netip.Prefix)),
+			str:      "fd00::/8",
+			want:     netip.MustParsePrefix("fd00::/8"),
+			wantOk:   true,
+		},
+		{
+			name:     "When value is *regexp.Regexp",
+			settable: reflect.ValueOf(new(*regexp.Regexp)),
+			str:      "^v[0-9]+$",
+			want:     regexp.MustCompile("^v[0-9]+$"),
+			wantOk:   true,
+		},
+		{
+			name:     "When value is an invalid *regexp.Regexp",
+			settable: reflect.ValueOf(new(*regexp.Regexp)),
+			str:      "v[0-9",
+			want:     nil,
+			wantOk:   false,
+		},
+		{
+			name:     "When value is *big.Int",
+			settable: reflect.ValueOf(new(*big.Int)),
+			str:      "123456789012345678901234567890",
+			want:     func() *big.Int { n, _ := new(big.Int).SetString("123456789012345678901234567890", 10); return n }(),
+			wantOk:   true,
+		},
+		{
+			name:     "When value is *big.Float",
+			settable: reflect.ValueOf(new(*big.Float)),
+			str:      "1.5",
+			want:     func() *big.Float { f, _ := new(big.Float).SetString("1.5"); return f }(),
+			wantOk:   true,
+		},
+		{
+			name:     "When value is os.FileMode",
+			settable: reflect.ValueOf(new(os.FileMode)),
+			str:      "0640",
+			want:     os.FileMode(0o640),
+			wantOk:   true,
+		},
+		{
+			name:     "When value is os.FileMode with the 0o prefix",
+			settable: reflect.ValueOf(new(os.FileMode)),
+			str:      "0o755",
+			want:     os.FileMode(0o755),
+			wantOk:   true,
+		},
+		{
+			name:     "When value is an invalid os.FileMode",
+			settable: reflect.ValueOf(new(os.FileMode)),
+			str:      "0999",
+			want:     nil,
+			wantOk:   false,
+		},
+		{
+			name:     "When value implements encoding.TextUnmarshaler",
+			settable: reflect.ValueOf(new(slog.Level)),
+			str:      "warn",
+			want:     slog.LevelWarn,
+			wantOk:   true,
+		},
+		{
+			name:     "When value is an invalid encoding.TextUnmarshaler",
+			settable: reflect.ValueOf(new(slog.Level)),
+			str:      "loud",
+			want:     nil,
+			wantOk:   false,
+		},
+		{
+			name:     "When value is a struct implementing encoding.TextUnmarshaler",
+			settable: reflect.ValueOf(new(netip.Addr)),
+			str:      "10.0.0.1",
+			want:     netip.MustParseAddr("10.0.0.1"),
+			wantOk:   true,
+		},
+		{
+			name:     "When value is a slice implementing encoding.TextUnmarshaler",
+			settable: reflect.ValueOf(new(net.IP)),
+			str:      "10.0.0.1",
+			want:     net.ParseIP("10.0.0.1"),
+			wantOk:   true,
+		},
+		{
+			name:     "When value implements flag.Value",
+			settable: reflect.ValueOf(new(testLevel)),
+			str:      "high",
+			want:     testLevel(2),
+			wantOk:   true,
+		},
+		{
+			name:     "When value is a pointer to an encoding.TextUnmarshaler",
+			settable: reflect.ValueOf(new(*slog.Level)),
+			str:      "error",
+			want:     func() *slog.Level { level := slog.LevelError; return &level }(),
+			wantOk:   true,
+		},
+		{
+			name:     "When value is a pointer to int",
+			settable: reflect.ValueOf(new(*int)),
+			str:      "42",
+			want:     func() *int { n := 42; return &n }(),
+			wantOk:   true,
+		},
+		{
+			name:     "When value is a pointer to an unsupported struct",
+			settable: reflect.ValueOf(new(*struct{ Field string })),
+			str:      "value",
+			want:     nil,
+			wantOk:   false,
+		},
+		{
+			name:     "When value is an unsupported struct",
+			settable: reflect.ValueOf(new(struct{ Field string })),
+			str:      "value",
+			want:     nil,
+			wantOk:   false,
+		},
 		{
 			name:     "When value is complex",
 			settable: reflect.ValueOf(new(complex128)),
@@ -150,7 +377,7 @@ func TestConvertAndSetValue(t *testing.T) {
 		t.Run(test.name, func(t *testing.T) {
 			t.Parallel()
 
-			ok := convertAndSetValue(test.settable, test.str)
+			ok := convertAndSetValue(nil, test.settable, test.str) == nil
 			if ok != test.wantOk {
 				t.Errorf("convertAndSetValue() ok = %v, wantOk %v", ok, test.wantOk)
 			}
@@ -161,3 +388,122 @@ func TestConvertAndSetValue(t *testing.T) {
 		})
 	}
 }
+
+func TestConvertAndSetTyped(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		settable reflect.Value
+		val      any  // typed value to set
+		want     any  // expected value after setting
+		wantOk   bool // true if it is expected that the value was set
+	}{
+		{
+			name:     "When value is bool and target is bool",
+			settable: reflect.ValueOf(new(bool)),
+			val:      true,
+			want:     true,
+			wantOk:   true,
+		},
+		{
+			name:     "When value is bool and target is string",
+			settable: reflect.ValueOf(new(string)),
+			val:      true,
+			want:     nil,
+			wantOk:   false,
+		},
+		{
+			name:     "When value is number and target is int",
+			settable: reflect.ValueOf(new(int)),
+			val:      json.Number("123"),
+			want:     123,
+			wantOk:   true,
+		},
+		{
+			name:     "When value is number and target is uint",
+			settable: reflect.ValueOf(new(uint16)),
+			val:      json.Number("8080"),
+			want:     uint16(8080),
+			wantOk:   true,
+		},
+		{
+			name:     "When value is number and target is float",
+			settable: reflect.ValueOf(new(float64)),
+			val:      json.Number("1.5"),
+			want:     1.5,
+			wantOk:   true,
+		},
+		{
+			name:     "When value is a fraction and target is int",
+			settable: reflect.ValueOf(new(int)),
+			val:      json.Number("1.5"),
+			want:     nil,
+			wantOk:   false,
+		},
+		{
+			name:     "When value overflows the target",
+			settable: reflect.ValueOf(new(int8)),
+			val:      json.Number("300"),
+			want:     nil,
+			wantOk:   false,
+		},
+		{
+			name:     "When value is negative and target is uint",
+			settable: reflect.ValueOf(new(uint)),
+			val:      json.Number("-1"),
+			want:     nil,
+			wantOk:   false,
+		},
+		{
+			name:     "When value is int64 and target is float",
+			settable: reflect.ValueOf(new(float32)),
+			val:      int64(2),
+			want:     float32(2),
+			wantOk:   true,
+		},
+		{
+			name:     "When value is float64 and target is int",
+			settable: reflect.ValueOf(new(int)),
+			val:      0.5,
+			want:     nil,
+			wantOk:   false,
+		},
+		{
+			name:     "When value is time.Time and target is time.Time",
+			settable: reflect.ValueOf(new(time.Time)),
+			val:      time.Date(1979, 5, 27, 0, 0, 0, 0, time.UTC),
+			want:     time.Date(1979, 5, 27, 0, 0, 0, 0, time.UTC),
+			wantOk:   true,
+		},
+		{
+			name:     "When value is number and target is os.FileMode",
+			settable: reflect.ValueOf(new(os.FileMode)),
+			val:      json.Number("644"),
+			want:     nil,
+			wantOk:   false,
+		},
+		{
+			name:     "When value is number and target is time.Duration",
+			settable: reflect.ValueOf(new(time.Duration)),
+			val:      json.Number("5"),
+			want:     nil,
+			wantOk:   false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			ok := convertAndSetTyped(nil, test.settable, test.val)
+			if ok != test.wantOk {
+				t.Errorf("convertAndSetTyped() ok = %v, wantOk %v", ok, test.wantOk)
+			}
+
+			if ok && !reflect.DeepEqual(test.settable.Elem().Interface(), test.want) {
+				t.Errorf("convertAndSetTyped() = %v, want %v", test.settable.Elem().Interface(), test.want)
+			}
+		})
+	}
+}
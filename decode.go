@@ -0,0 +1,632 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultTag     = "default"
+	requiredTag    = "required"
+	requiredOption = "required"
+	secretTag      = "secret"
+	secretOption   = "secret"
+	layoutTag      = "layout"
+)
+
+// decode reads the config map and populates the target struct with the values.
+// References to other keys, such as ${DB_HOST}, are expanded against the whole config map before conversion.
+// Fields without a key in the config map are set from their `default` tag, if any. Otherwise, fields
+// tagged with `required:"true"` or `config:"name,required"` are reported as missing.
+// It returns a *DecodeError if any sources or fields failed to read, expand or convert, or are missing.
+// Failures of fields are only reported by the decode that found them.
+func (b *Builder) decode(target any, prefix string) error {
+	structPtr := reflect.ValueOf(target)
+
+	if structPtr.Kind() != reflect.Ptr || structPtr.Elem().Kind() != reflect.Struct {
+		panic("config: failed to decode. target must be a struct pointer")
+	}
+
+	dec := b.newDecoding()
+
+	dec.decodeStruct(structPtr, b.normalizeKey(prefix), "")
+	b.decoded = true
+	dec.runValidators(structPtr, strings.TrimSuffix(b.normalizeKey(prefix), b.keyDelimiter()), "")
+
+	if len(dec.failures) == 0 {
+		return nil
+	}
+
+	// Redact once all fields are decoded, when every secret field is known.
+	for i, fieldErr := range dec.failures {
+		dec.failures[i] = dec.redact(fieldErr)
+	}
+
+	sortFieldErrors(dec.failures) // sort for deterministic output
+
+	return &DecodeError{Fields: dec.failures}
+}
+
+// decoding is the state of a single decode: the interpolator of the config map, the secret files read so far,
+// the keys that fields decode, and the failures found so far, which start with the failures of the sources.
+// Failures of fields are kept apart from the builder, so that decoding again, into the same struct or another,
+// does not report them twice.
+type decoding struct {
+	*Builder
+	in          *interpolator
+	secretFiles map[string]secretFile
+	fieldKeys   map[string]bool
+	failures    []FieldError
+}
+
+// newDecoding returns the state of a new decode, whose interpolator resolves references against the config map
+// and the contents of the secret files.
+func (b *Builder) newDecoding() *decoding {
+	dec := &decoding{
+		Builder:     b,
+		secretFiles: make(map[string]secretFile),
+		fieldKeys:   make(map[string]bool),
+		failures:    slices.Clone(b.failedFields),
+	}
+
+	dec.in = newInterpolator(b.configMap, b.normalizeKey, b.isFromEnv, dec)
+
+	return dec
+}
+
+// addFailure records a failure of the decode. Failures of a key without a source are attributed to the source
+// that set the key.
+func (b *decoding) addFailure(fieldErr FieldError) {
+	if fieldErr.Key != "" && fieldErr.Source == "" {
+		fieldErr.Source = b.originOf(fieldErr.Key)
+	}
+
+	b.failures = append(b.failures, fieldErr)
+}
+
+// decodeStruct populates the fields of the struct that structPtr points to with the values of the keys
+// starting with prefix. The path is the Go path of the struct from the root, used to report errors.
+func (b *decoding) decodeStruct(structPtr reflect.Value, prefix string, path string) {
+	m := make(map[string]fieldInfo)
+	mapKeysToFields(structPtr, m, prefix, b.keyDelimiter(), b.decoders)
+
+	for key := range m {
+		b.fieldKeys[b.normalizeKey(key)] = true
+	}
+
+	for key, field := range m {
+		key = b.normalizeKey(key)
+		field.path = joinKey(path, field.path, ".")
+
+		if field.secret {
+			b.markSecret(key)
+		}
+
+		failures := len(b.failures)
+
+		if !b.decodeField(key, field) && !b.applyDefault(key, field) && isRequired(field.field) {
+			b.addFailure(FieldError{Key: key, FieldPath: field.path, Cause: ErrMissingRequired})
+		}
+
+		// Values that failed to decode, or are missing, are not validated, to report a single failure per value.
+		if len(b.failures) == failures {
+			b.validateField(key, field)
+		}
+	}
+}
+
+// decodeField populates a field from the config map. It returns false if no key was found for the field.
+func (b *decoding) decodeField(key string, field fieldInfo) bool {
+	if inner, ok := secretInner(field.value); ok {
+		field.value = inner.Elem()
+	}
+
+	d := b.fieldDecoders(field.field)
+
+	switch kind := field.value.Kind(); {
+	case kind == reflect.Slice && !b.isWholeValue(field.value.Type()):
+		return b.decodeSlice(d, key, field.value.Addr(), field.path)
+	case kind == reflect.Map && !b.isWholeValue(field.value.Type()):
+		return b.decodeMap(d, key, field.value.Addr(), field.path)
+	default:
+		if !b.hasKey(key) {
+			return false
+		}
+
+		stringValue, ok := b.lookup(key, field.value.Type(), field.path)
+		if !ok {
+			return true
+		}
+
+		if err := b.convertAndSet(d, field.value, key, stringValue); err != nil {
+			b.addFailure(FieldError{Key: key, FieldPath: field.path, RawValue: stringValue, Cause: err})
+		}
+
+		return true
+	}
+}
+
+// applyDefault sets the value of the `default` tag on a field that has no key in the config map.
+// Defaults go through the same conversions as values from the config map: slices are split by the
+// slice delimiter and maps use the inline map syntax. An invalid default is a programming error,
+// which is reported with the path of the field. It returns false if the field has no `default` tag.
+func (b *decoding) applyDefault(key string, field fieldInfo) bool {
+	def, ok := field.field.Tag.Lookup(defaultTag)
+	if !ok {
+		return false
+	}
+
+	var err error
+
+	d := b.fieldDecoders(field.field)
+
+	switch kind := field.value.Kind(); {
+	case kind == reflect.Slice && !b.isWholeValue(field.value.Type()):
+		values := stringToSlice(def, b.sliceDelimiter)
+		if failures := convertAndSetSlice(d, field.value.Addr(), values); len(failures) > 0 {
+			err = failures[0].err
+		}
+	case kind == reflect.Map && !b.isWholeValue(field.value.Type()):
+		if failures := b.setInlineMap(d, field.value.Addr(), def); len(failures) > 0 {
+			err = failures[0].err
+		}
+	default:
+		err = convertAndSetValue(d, field.value, def)
+	}
+
+	if err != nil {
+		b.addFailure(FieldError{Key: key, FieldPath: field.path, RawValue: def, Cause: errors.Wrap(err, "invalid default")})
+	}
+
+	return true
+}
+
+// isRequired returns true if a field is tagged with `required:"true"` or `config:"name,required"`.
+func isRequired(field reflect.StructField) bool {
+	required, err := strconv.ParseBool(field.Tag.Get(requiredTag))
+
+	return (err == nil && required) || hasTagOption(field, requiredOption)
+}
+
+// isSecret returns true if a field is tagged with `secret:"true"` or `config:"name,secret"`,
+// or if it is a Secret.
+func isSecret(field reflect.StructField) bool {
+	secret, err := strconv.ParseBool(field.Tag.Get(secretTag))
+
+	return (err == nil && secret) || hasTagOption(field, secretOption) || isSecretType(field.Type)
+}
+
+// decodeSlice populates a slice field, either from indexed keys (key.0, key.1, ...) as produced by
+// structured sources such as YAML, or from a single value split by the slice delimiter.
+// Indexed keys take precedence over a delimited value, unless it comes from a source of higher precedence
+// than all of them. Slices of structs, and of pointers to structs, are only populated from indexed keys,
+// e.g. servers.0.host or, with "_" as struct delimiter, SERVERS_0_HOST.
+// It returns false if no key was found for the slice.
+func (b *decoding) decodeSlice(d decoders, key string, slicePtr reflect.Value, path string) bool {
+	elemType := slicePtr.Elem().Type().Elem()
+	isStructElem := b.isStructValue(elemType)
+
+	if !isStructElem && b.outranksElements(key) {
+		return b.decodeDelimitedSlice(d, key, slicePtr, path)
+	}
+
+	length, ok := b.indexedLength(key, isStructElem, path)
+	if !ok {
+		return true
+	}
+
+	switch {
+	case isStructElem:
+		b.decodeStructSlice(key, slicePtr, length, path)
+
+		// An empty value, as for an empty sequence in YAML, sets an empty slice.
+		if length == 0 && b.hasKey(key) {
+			slicePtr.Elem().Set(reflect.MakeSlice(slicePtr.Elem().Type(), 0, 0))
+
+			return true
+		}
+
+		return length > 0
+	case length > 0:
+		b.decodeIndexedSlice(d, key, slicePtr, length, path)
+
+		return true
+	default:
+		return b.decodeDelimitedSlice(d, key, slicePtr, path)
+	}
+}
+
+// decodeDelimitedSlice populates a slice of scalars from the value of key split by the slice delimiter.
+// Elements that fail to convert are left out of the slice. It returns false if the key is absent.
+func (b *decoding) decodeDelimitedSlice(d decoders, key string, slicePtr reflect.Value, path string) bool {
+	if !b.hasKey(key) {
+		return false
+	}
+
+	stringValue, ok := b.lookup(key, slicePtr.Elem().Type().Elem(), path)
+	if !ok {
+		return true
+	}
+
+	for _, failure := range convertAndSetSlice(d, slicePtr, stringToSlice(stringValue, b.sliceDelimiter)) {
+		b.addFailure(FieldError{
+			Key:       key,
+			FieldPath: fmt.Sprintf("%s[%d]", path, failure.index),
+			RawValue:  failure.value,
+			Cause:     failure.err,
+		})
+	}
+
+	return true
+}
+
+// outranksElements returns true if the value of key comes from a source of higher precedence than every
+// indexed key of the list, such as key.0, so that the value is split rather than the indexed keys used.
+func (b *Builder) outranksElements(key string) bool {
+	if _, ok := b.origins[key]; !ok {
+		return false
+	}
+
+	for k := range b.configMap {
+		if k != key && b.isListElement(k, key) && !b.outranks(key, k) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isStructValue returns true for struct types that are mapped field by field, and for pointers to them.
+func (b *Builder) isStructValue(t reflect.Type) bool {
+	return isStructType(t, b.decoders) || (t.Kind() == reflect.Pointer && isStructType(t.Elem(), b.decoders))
+}
+
+// decodeIndexedSlice populates a slice of scalars from the indexed keys key.0 to key.<length-1>.
+// Elements that fail to convert are left out of the slice.
+func (b *decoding) decodeIndexedSlice(
+	d decoders,
+	key string,
+	slicePtr reflect.Value,
+	length int,
+	path string,
+) {
+	sliceVal := slicePtr.Elem()
+
+	for i := range length {
+		elemKey := key + b.keyDelimiter() + strconv.Itoa(i)
+		elemPath := fmt.Sprintf("%s[%d]", path, i)
+
+		stringValue, ok := b.lookup(elemKey, sliceVal.Type().Elem(), elemPath)
+		if !ok {
+			continue
+		}
+
+		elemPtr := reflect.New(sliceVal.Type().Elem())
+
+		if err := b.convertAndSet(d, elemPtr, elemKey, stringValue); err != nil {
+			b.addFailure(FieldError{Key: elemKey, FieldPath: elemPath, RawValue: stringValue, Cause: err})
+
+			continue
+		}
+
+		sliceVal.Set(reflect.Append(sliceVal, elemPtr.Elem()))
+	}
+}
+
+// decodeStructSlice populates a slice of structs, or of pointers to structs, from the indexed keys
+// key.0.<field> to key.<length-1>.<field>, as produced by structured sources such as arrays of tables in TOML.
+func (b *decoding) decodeStructSlice(key string, slicePtr reflect.Value, length int, path string) {
+	sliceVal := slicePtr.Elem()
+	elemType := sliceVal.Type().Elem()
+	isPtrElem := elemType.Kind() == reflect.Pointer
+
+	if isPtrElem {
+		elemType = elemType.Elem()
+	}
+
+	for i := range length {
+		elemPtr := reflect.New(elemType)
+		elemPrefix := key + b.keyDelimiter() + strconv.Itoa(i) + b.keyDelimiter()
+		b.decodeStruct(elemPtr, elemPrefix, fmt.Sprintf("%s[%d]", path, i))
+
+		if isPtrElem {
+			sliceVal.Set(reflect.Append(sliceVal, elemPtr))
+		} else {
+			sliceVal.Set(reflect.Append(sliceVal, elemPtr.Elem()))
+		}
+	}
+}
+
+// decodeMap populates a map field from two forms, which can be combined:
+//   - An inline value split by the map delimiters, e.g. labels=team:x,env:y.
+//   - All keys sharing the field's key as prefix, e.g. labels.team=x and labels.env=y.
+//     For struct values, the map key is the segment after the prefix, e.g. upstreams.a.host.
+//
+// Keys sharing the prefix take precedence over the inline value, unless it comes from a source of higher
+// precedence than theirs, in which case they are left out. Map keys and scalar values are converted
+// with the same converters as any other field. It returns false if no key was found for the map.
+func (b *decoding) decodeMap(d decoders, key string, mapPtr reflect.Value, path string) bool {
+	valType := mapPtr.Elem().Type().Elem()
+	isStructVal := b.isStructValue(valType)
+
+	found := b.hasKey(key)
+
+	if stringValue, ok := b.lookup(key, valType, path); ok && !isStructVal {
+		for _, failure := range b.setInlineMap(d, mapPtr, stringValue) {
+			b.addFailure(FieldError{
+				Key:       key,
+				FieldPath: fmt.Sprintf("%s[%s]", path, failure.key),
+				RawValue:  failure.value,
+				Cause:     failure.err,
+			})
+		}
+	}
+
+	prefix := key + b.keyDelimiter()
+	mapKeys := b.mapKeys(prefix, isStructVal)
+
+	for _, mapKey := range mapKeys {
+		if !isStructVal && b.outranks(key, prefix+mapKey) {
+			continue
+		}
+
+		valPtr := reflect.New(valType)
+		entryPath := fmt.Sprintf("%s[%s]", path, mapKey)
+
+		if isStructVal {
+			b.decodeMapStruct(valPtr, prefix+mapKey+b.keyDelimiter(), entryPath)
+		} else {
+			stringValue, ok := b.lookup(prefix+mapKey, valType, entryPath)
+			if !ok {
+				continue
+			}
+
+			if err := b.convertAndSet(d, valPtr, prefix+mapKey, stringValue); err != nil {
+				b.addFailure(FieldError{Key: prefix + mapKey, FieldPath: entryPath, RawValue: stringValue, Cause: err})
+
+				continue
+			}
+		}
+
+		if err := setMapEntry(d, mapPtr, mapKey, valPtr.Elem()); err != nil {
+			b.addFailure(FieldError{Key: prefix + mapKey, FieldPath: entryPath, RawValue: mapKey, Cause: err})
+		}
+	}
+
+	return found || len(mapKeys) > 0
+}
+
+// mapEntryError is the error of an inline map entry that failed to convert.
+type mapEntryError struct {
+	key   string
+	value string
+	err   error
+}
+
+// setInlineMap sets the entries of an inline map value, such as "team:x,env:y", on the map.
+// It returns the entries that failed to convert.
+func (b *Builder) setInlineMap(d decoders, mapPtr reflect.Value, str string) []mapEntryError {
+	var failures []mapEntryError
+
+	for _, pair := range stringToSlice(str, b.mapPairDelimiter) {
+		mapKey, mapValue, found := strings.Cut(pair, b.mapKeyValueDelimiter)
+		mapKey, mapValue = strings.TrimSpace(mapKey), strings.TrimSpace(mapValue)
+
+		if !found {
+			failures = append(failures, mapEntryError{
+				key:   mapKey,
+				value: pair,
+				err:   errors.Errorf("missing %q between the key and the value", b.mapKeyValueDelimiter),
+			})
+
+			continue
+		}
+
+		valPtr := reflect.New(mapPtr.Elem().Type().Elem())
+
+		err := convertAndSetValue(d, valPtr, mapValue)
+		if err == nil {
+			err = setMapEntry(d, mapPtr, mapKey, valPtr.Elem())
+		}
+
+		if err != nil {
+			failures = append(failures, mapEntryError{key: mapKey, value: pair, err: err})
+		}
+	}
+
+	return failures
+}
+
+// decodeMapStruct populates a struct, or a pointer to a struct, used as a map value.
+func (b *decoding) decodeMapStruct(valPtr reflect.Value, prefix string, path string) {
+	if valPtr.Elem().Kind() == reflect.Pointer {
+		valPtr.Elem().Set(reflect.New(valPtr.Elem().Type().Elem()))
+		valPtr = valPtr.Elem()
+	}
+
+	b.decodeStruct(valPtr, prefix, path)
+}
+
+// setMapEntry converts the map key and sets the entry on the map, creating the map if it is nil.
+// It returns an error if the key failed to convert.
+func setMapEntry(d decoders, mapPtr reflect.Value, mapKey string, val reflect.Value) error {
+	mapVal := mapPtr.Elem()
+	keyPtr := reflect.New(mapVal.Type().Key())
+
+	if err := convertAndSetValue(d, keyPtr, mapKey); err != nil {
+		return errors.Wrap(err, "invalid map key")
+	}
+
+	if mapVal.IsNil() {
+		mapVal.Set(reflect.MakeMap(mapVal.Type()))
+	}
+
+	mapVal.SetMapIndex(keyPtr.Elem(), val)
+
+	return nil
+}
+
+// mapKeys returns the sorted map keys found in the config map under prefix. When nested is true,
+// the values are structs and the map key is the segment up to the next struct delimiter,
+// otherwise it is the whole rest of the key, which may contain the struct delimiter itself.
+//
+// Example:
+//
+//	// configMap: map[labels.team:x labels.app.kubernetes.io/name:y]
+//	b.mapKeys("labels.", false) // Output: []string{"app.kubernetes.io/name", "team"}
+func (b *Builder) mapKeys(prefix string, nested bool) []string {
+	seen := make(map[string]bool)
+
+	var keys []string
+
+	for k := range b.configMap {
+		mapKey, found := strings.CutPrefix(k, prefix)
+		if !found || mapKey == "" {
+			continue
+		}
+
+		if nested {
+			var hasTail bool
+			if mapKey, _, hasTail = strings.Cut(mapKey, b.keyDelimiter()); !hasTail {
+				continue
+			}
+		}
+
+		if !seen[mapKey] {
+			seen[mapKey] = true
+			keys = append(keys, mapKey)
+		}
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// convertAndSet sets the value of a key on the settable, with the decoders of the field. A typed value,
+// as decoded by structured sources such as JSON, is set as is when it fits the settable,
+// otherwise the string value is converted.
+func (b *Builder) convertAndSet(d decoders, settable reflect.Value, key string, stringValue string) error {
+	if typed, ok := b.typedMap[key]; ok && convertAndSetTyped(d, settable, typed) {
+		return nil
+	}
+
+	return convertAndSetValue(d, settable, stringValue)
+}
+
+// fieldDecoders returns the decoders that convert the values of a field: the decoders registered with
+// WithDecoder and, for a field tagged with `layout:"..."`, a decoder of time.Time in that layout.
+func (b *Builder) fieldDecoders(field reflect.StructField) decoders {
+	layout, ok := field.Tag.Lookup(layoutTag)
+	if !ok {
+		return b.decoders
+	}
+
+	d := make(decoders, len(b.decoders)+1)
+	maps.Copy(d, b.decoders)
+
+	d[timeType] = func(str string) (reflect.Value, error) {
+		t, err := time.Parse(layout, str)
+
+		return reflect.ValueOf(t), err
+	}
+
+	return d
+}
+
+// lookup returns the expanded value of a key converted to target, and false if the key is absent,
+// ambiguous or failed to expand. A value such as file:///run/secrets/token, and a key without value whose
+// secret file key, such as token_file, is set, are replaced with the contents of the file they name.
+func (b *decoding) lookup(key string, target reflect.Type, path string) (string, bool) {
+	if fileKey, ok := b.secretFileKey(key); ok {
+		return b.lookupSecretFile(key, fileKey, target, path)
+	}
+
+	if _, ok := b.configMap[key]; !ok || b.collided(key, path) {
+		return "", false
+	}
+
+	stringValue, err := b.in.resolve(key)
+	if err != nil {
+		b.addFailure(FieldError{
+			Key:       key,
+			FieldPath: path,
+			RawValue:  b.configMap[key],
+			Cause:     errors.Wrap(err, "interpolate"),
+		})
+
+		return "", false
+	}
+
+	return stringValue, true
+}
+
+// collided returns true if several keys of a source collapse to key, which is reported for the field at path.
+func (b *decoding) collided(key string, path string) bool {
+	keys, ok := b.collisions[key]
+	if ok {
+		b.addFailure(FieldError{Key: key, FieldPath: path, Cause: collisionError(keys)})
+	}
+
+	return ok
+}
+
+// indexedLength returns the length of the list stored under key as indexed keys, inferred from
+// the highest index present. When nested is true, the elements are structs and only keys such as
+// key.0.<field> are considered, otherwise only keys such as key.0 are.
+// The first gap in the indices is reported for the element of the field at path, in which case false is returned.
+//
+// Example:
+//
+//	// configMap: map[servers.0.host:a servers.1.host:b servers.1.port:80]
+//	b.indexedLength("servers", true, "Servers") // Output: 2, true
+func (b *decoding) indexedLength(key string, nested bool, path string) (int, bool) {
+	prefix := key + b.keyDelimiter()
+	present := make(map[int]bool)
+	length := 0
+
+	for k := range b.configMap {
+		rest, found := strings.CutPrefix(k, prefix)
+		if !found {
+			continue
+		}
+
+		indexStr, _, hasTail := strings.Cut(rest, b.keyDelimiter())
+		if hasTail != nested {
+			continue
+		}
+
+		index, ok := parseIndex(indexStr)
+		if !ok {
+			continue
+		}
+
+		present[index] = true
+		length = max(length, index+1)
+	}
+
+	if len(present) == length {
+		return length, true
+	}
+
+	missing := 0
+	for present[missing] {
+		missing++
+	}
+
+	b.addFailure(FieldError{
+		Key:       key + b.keyDelimiter() + strconv.Itoa(missing),
+		FieldPath: fmt.Sprintf("%s[%d]", path, missing),
+		Cause:     fmt.Errorf("%w, highest index is %d", ErrMissingListElement, length-1),
+	})
+
+	return 0, false
+}
@@ -0,0 +1,954 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDecode(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		config  map[string]string
+		target  any
+		prefix  string
+		wantOut any
+		wantErr bool
+	}{
+		{
+			name: "When config map matches struct fields then it should populate the struct",
+			config: map[string]string{
+				"Field1": "value1",
+				"Field2": "value2",
+			},
+			target: &struct {
+				Field1 string
+				Field2 string
+			}{},
+			prefix: "",
+			wantOut: &struct {
+				Field1 string
+				Field2 string
+			}{
+				Field1: "value1",
+				Field2: "value2",
+			},
+			wantErr: false,
+		},
+		{
+			name: "When config map has extra fields then it should ignore them",
+			config: map[string]string{
+				"Field1": "value1",
+				"Field2": "value2",
+				"Field3": "value3",
+			},
+			target: &struct {
+				Field1 string
+				Field2 string
+			}{},
+			prefix: "",
+			wantOut: &struct {
+				Field1 string
+				Field2 string
+			}{
+				Field1: "value1",
+				Field2: "value2",
+			},
+			wantErr: false,
+		},
+		{
+			name: "When config map is missing fields then it should leave them as zero values",
+			config: map[string]string{
+				"Field1": "value1",
+			},
+			target: &struct {
+				Field1 string
+				Field2 string
+			}{},
+			prefix: "",
+			wantOut: &struct {
+				Field1 string
+				Field2 string
+			}{
+				Field1: "value1",
+			},
+			wantErr: false,
+		},
+		{
+			name: "When config map has invalid values then it should return an error",
+			config: map[string]string{
+				"Field1": "value1",
+				"Field2": "not a number",
+			},
+			target: &struct {
+				Field1 string
+				Field2 int
+			}{},
+			prefix: "",
+			wantOut: &struct {
+				Field1 string
+				Field2 int
+			}{
+				Field1: "value1",
+				Field2: 0,
+			},
+			wantErr: true,
+		},
+		{
+			name: "When config map has a delimited value for a slice field then it should be split",
+			config: map[string]string{
+				"Field1": "a b  c",
+			},
+			target: &struct {
+				Field1 []string
+			}{},
+			prefix: "",
+			wantOut: &struct {
+				Field1 []string
+			}{
+				Field1: []string{"a", "b", "c"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "When config map has indexed keys for a slice field then they should take precedence",
+			config: map[string]string{
+				"Field1":   "ignored",
+				"Field1.0": "a b",
+				"Field1.1": "c",
+			},
+			target: &struct {
+				Field1 []string
+			}{},
+			prefix: "",
+			wantOut: &struct {
+				Field1 []string
+			}{
+				Field1: []string{"a b", "c"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "When config map has references then they should be expanded before conversion",
+			config: map[string]string{
+				"Field1": "${host}:${port}",
+				"Field2": "${port}",
+				"host":   "localhost",
+				"port":   "8080",
+			},
+			target: &struct {
+				Field1 string
+				Field2 int
+			}{},
+			prefix: "",
+			wantOut: &struct {
+				Field1 string
+				Field2 int
+			}{
+				Field1: "localhost:8080",
+				Field2: 8080,
+			},
+			wantErr: false,
+		},
+		{
+			name: "When config map has a reference cycle then it should return an error",
+			config: map[string]string{
+				"Field1": "${Field2}",
+				"Field2": "${Field1}",
+			},
+			target: &struct {
+				Field1 string
+				Field2 string
+			}{},
+			prefix:  "",
+			wantOut: nil,
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			b := New()
+			b.mergeValues(test.config, nil, Origin{}, nil)
+
+			err := b.decode(test.target, test.prefix)
+			if (err != nil) != test.wantErr {
+				t.Errorf(failTestMessage("decode", test.wantErr, err))
+			}
+
+			if !test.wantErr && !reflect.DeepEqual(test.target, test.wantOut) {
+				t.Errorf(failTestMessage("decode", test.wantOut, test.target))
+			}
+		})
+	}
+}
+
+type testUpstream struct {
+	Host string `config:"host"`
+	Port int    `config:"port"`
+}
+
+func TestDecodeIndexedSlices(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		delimiter string
+		config    map[string]string
+		target    any
+		wantOut   any
+		wantErr   bool
+	}{
+		{
+			name:      "When indexed keys address a slice of structs then it should be populated",
+			delimiter: ".",
+			config: map[string]string{
+				"servers.0.host": "a",
+				"servers.0.port": "80",
+				"servers.1.host": "b",
+			},
+			target: &struct {
+				Servers []testUpstream `config:"servers"`
+			}{},
+			wantOut: &struct {
+				Servers []testUpstream `config:"servers"`
+			}{
+				Servers: []testUpstream{{Host: "a", Port: 80}, {Host: "b"}},
+			},
+			wantErr: false,
+		},
+		{
+			name:      "When indexed keys address a slice of struct pointers then it should be populated",
+			delimiter: ".",
+			config: map[string]string{
+				"servers.0.host": "a",
+				"servers.1.port": "443",
+			},
+			target: &struct {
+				Servers []*testUpstream `config:"servers"`
+			}{},
+			wantOut: &struct {
+				Servers []*testUpstream `config:"servers"`
+			}{
+				Servers: []*testUpstream{{Host: "a"}, {Port: 443}},
+			},
+			wantErr: false,
+		},
+		{
+			name:      "When env-style keys are used with '_' as delimiter then the slice should be populated",
+			delimiter: "_",
+			config: map[string]string{
+				"servers_0_host": "a",
+				"servers_1_host": "b",
+			},
+			target: &struct {
+				Servers []testUpstream `config:"servers"`
+			}{},
+			wantOut: &struct {
+				Servers []testUpstream `config:"servers"`
+			}{
+				Servers: []testUpstream{{Host: "a"}, {Host: "b"}},
+			},
+			wantErr: false,
+		},
+		{
+			name:      "When indices are not in order then the length should be inferred from the highest index",
+			delimiter: ".",
+			config: map[string]string{
+				"ports.2": "3",
+				"ports.0": "1",
+				"ports.1": "2",
+			},
+			target: &struct {
+				Ports []int `config:"ports"`
+			}{},
+			wantOut: &struct {
+				Ports []int `config:"ports"`
+			}{
+				Ports: []int{1, 2, 3},
+			},
+			wantErr: false,
+		},
+		{
+			name:      "When indices are not plain numbers then they should be ignored",
+			delimiter: ".",
+			config: map[string]string{
+				"ports.0":  "1",
+				"ports.+1": "2",
+				"ports.x":  "3",
+			},
+			target: &struct {
+				Ports []int `config:"ports"`
+			}{},
+			wantOut: &struct {
+				Ports []int `config:"ports"`
+			}{
+				Ports: []int{1},
+			},
+			wantErr: false,
+		},
+		{
+			name:      "When indices of a slice of structs have a gap then it should return an error",
+			delimiter: ".",
+			config: map[string]string{
+				"servers.0.host": "a",
+				"servers.2.host": "c",
+			},
+			target: &struct {
+				Servers []testUpstream `config:"servers"`
+			}{},
+			wantOut: nil,
+			wantErr: true,
+		},
+		{
+			name:      "When indices of a slice of scalars have a gap then it should return an error",
+			delimiter: ".",
+			config: map[string]string{
+				"ports.1": "2",
+			},
+			target: &struct {
+				Ports []int `config:"ports"`
+			}{},
+			wantOut: nil,
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			b := New(WithStructDelimiter(test.delimiter))
+			b.configMap = test.config
+
+			err := b.decode(test.target, "")
+			if (err != nil) != test.wantErr {
+				t.Errorf(failTestMessage("decode", test.wantErr, err))
+			}
+
+			if !test.wantErr && !reflect.DeepEqual(test.target, test.wantOut) {
+				t.Errorf(failTestMessage("decode", test.wantOut, test.target))
+			}
+		})
+	}
+}
+
+func TestDecodeFormsByPrecedence(t *testing.T) {
+	t.Parallel()
+
+	type target struct {
+		Hosts  []string          `config:"hosts"`
+		Labels map[string]string `config:"labels"`
+	}
+
+	yamlHosts := map[string]string{"hosts.0": "y1", "hosts.1": "y2", "hosts.2": "y3"}
+	envHosts := map[string]string{"hosts": "e1 e2"}
+	yamlLabels := map[string]string{"labels.team": "yamlteam", "labels.env": "prod"}
+	envLabels := map[string]string{"labels": "team:envteam"}
+
+	tests := []struct {
+		name       string
+		precedence []string
+		sources    []map[string]string
+		origins    []Origin
+		want       target
+	}{
+		{
+			name:       "When a delimited value outranks the indexed keys then it should be used",
+			precedence: []string{SourceYAML, SourceEnv},
+			sources:    []map[string]string{envHosts, yamlHosts},
+			origins:    []Origin{{Source: SourceEnv}, {Source: SourceYAML}},
+			want:       target{Hosts: []string{"e1", "e2"}},
+		},
+		{
+			name:       "When the indexed keys outrank a delimited value then they should be used",
+			precedence: []string{SourceEnv, SourceYAML},
+			sources:    []map[string]string{yamlHosts, envHosts},
+			origins:    []Origin{{Source: SourceYAML}, {Source: SourceEnv}},
+			want:       target{Hosts: []string{"y1", "y2", "y3"}},
+		},
+		{
+			name:       "When both forms rank the same then the indexed keys should be used",
+			precedence: nil,
+			sources:    []map[string]string{yamlHosts, envHosts},
+			origins:    []Origin{{Source: SourceYAML}, {Source: SourceEnv}},
+			want:       target{Hosts: []string{"y1", "y2", "y3"}},
+		},
+		{
+			name:       "When an inline map value outranks the prefixed keys then they should be left out",
+			precedence: []string{SourceYAML, SourceEnv},
+			sources:    []map[string]string{envLabels, yamlLabels},
+			origins:    []Origin{{Source: SourceEnv}, {Source: SourceYAML}},
+			want:       target{Labels: map[string]string{"team": "envteam"}},
+		},
+		{
+			name:       "When the prefixed keys outrank an inline map value then they should win over it",
+			precedence: []string{SourceEnv, SourceYAML},
+			sources:    []map[string]string{yamlLabels, envLabels},
+			origins:    []Origin{{Source: SourceYAML}, {Source: SourceEnv}},
+			want:       target{Labels: map[string]string{"team": "yamlteam", "env": "prod"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			b := New(WithPrecedence(test.precedence...))
+			for i, values := range test.sources {
+				b.mergeValues(values, nil, test.origins[i], nil)
+			}
+
+			var got target
+			if err := b.MapTo(&got); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf(failTestMessage("decode", test.want, got))
+			}
+		})
+	}
+}
+
+func TestIndexedLength(t *testing.T) {
+	t.Parallel()
+
+	b := New()
+	b.configMap = map[string]string{
+		"servers.0.host":   "a",
+		"servers.999.host": "z",
+	}
+
+	dec := b.newDecoding()
+
+	length, ok := dec.indexedLength("servers", true, "Servers")
+	if ok || length != 0 {
+		t.Errorf(failTestMessage("indexedLength", "0, false", fmt.Sprintf("%d, %v", length, ok)))
+	}
+
+	want := []string{"servers.1 (Servers[1]): missing list element, highest index is 999"}
+	if got := fieldErrorStrings(dec.failures); !reflect.DeepEqual(got, want) {
+		t.Errorf(failTestMessage("indexedLength", want, got))
+	}
+
+	if !errors.Is(dec.failures[0], ErrMissingListElement) {
+		t.Errorf(failTestMessage("indexedLength", ErrMissingListElement, dec.failures[0].Cause))
+	}
+}
+
+// fieldErrorStrings returns the messages of the field errors, or nil if there are none.
+func fieldErrorStrings(fieldErrs []FieldError) []string {
+	var messages []string
+	for _, fieldErr := range fieldErrs {
+		messages = append(messages, fieldErr.Error())
+	}
+
+	return messages
+}
+
+// decodeErrorFields returns the field errors of a *DecodeError, or nil if err is not one.
+func decodeErrorFields(err error) []FieldError {
+	var decodeErr *DecodeError
+	if !errors.As(err, &decodeErr) {
+		return nil
+	}
+
+	return decodeErr.Fields
+}
+
+func TestDecodeMaps(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		opts    []Option
+		config  map[string]string
+		target  any
+		wantOut any
+		wantErr bool
+	}{
+		{
+			name:   "When value is inline then it should be split into pairs",
+			config: map[string]string{"labels": "team: x, env:y"},
+			target: &struct {
+				Labels map[string]string `config:"labels"`
+			}{},
+			wantOut: &struct {
+				Labels map[string]string `config:"labels"`
+			}{
+				Labels: map[string]string{"team": "x", "env": "y"},
+			},
+			wantErr: false,
+		},
+		{
+			name:   "When custom delimiters are set then they should be used for inline values",
+			opts:   []Option{WithMapDelimiters(";", "=")},
+			config: map[string]string{"limits": "a=1;b=2"},
+			target: &struct {
+				Limits map[string]int `config:"limits"`
+			}{},
+			wantOut: &struct {
+				Limits map[string]int `config:"limits"`
+			}{
+				Limits: map[string]int{"a": 1, "b": 2},
+			},
+			wantErr: false,
+		},
+		{
+			name: "When keys share the field prefix then they should become map entries",
+			config: map[string]string{
+				"labels":                        "team:inline,env:inline",
+				"labels.team":                   "x",
+				"labels.app.kubernetes.io/name": "y",
+				"labelsx":                       "ignored",
+			},
+			target: &struct {
+				Labels map[string]string `config:"labels"`
+			}{},
+			wantOut: &struct {
+				Labels map[string]string `config:"labels"`
+			}{
+				Labels: map[string]string{"team": "x", "env": "inline", "app.kubernetes.io/name": "y"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "When map values are structs then they should be populated by prefix",
+			config: map[string]string{
+				"upstreams.a.host": "a.example.com",
+				"upstreams.a.port": "80",
+				"upstreams.b.host": "b.example.com",
+			},
+			target: &struct {
+				Upstreams map[string]testUpstream `config:"upstreams"`
+			}{},
+			wantOut: &struct {
+				Upstreams map[string]testUpstream `config:"upstreams"`
+			}{
+				Upstreams: map[string]testUpstream{
+					"a": {Host: "a.example.com", Port: 80},
+					"b": {Host: "b.example.com"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name:   "When map values are struct pointers then they should be populated by prefix",
+			config: map[string]string{"upstreams.a.port": "80"},
+			target: &struct {
+				Upstreams map[string]*testUpstream `config:"upstreams"`
+			}{},
+			wantOut: &struct {
+				Upstreams map[string]*testUpstream `config:"upstreams"`
+			}{
+				Upstreams: map[string]*testUpstream{"a": {Port: 80}},
+			},
+			wantErr: false,
+		},
+		{
+			name:   "When no keys match then the map should be left nil",
+			config: map[string]string{"other": "value"},
+			target: &struct {
+				Labels map[string]string `config:"labels"`
+			}{},
+			wantOut: &struct {
+				Labels map[string]string `config:"labels"`
+			}{},
+			wantErr: false,
+		},
+		{
+			name:   "When an inline pair has no key-value delimiter then it should return an error",
+			config: map[string]string{"labels": "team:x,env"},
+			target: &struct {
+				Labels map[string]string `config:"labels"`
+			}{},
+			wantOut: nil,
+			wantErr: true,
+		},
+		{
+			name:   "When a value fails to convert then it should return an error",
+			config: map[string]string{"limits.a": "not a number"},
+			target: &struct {
+				Limits map[string]int `config:"limits"`
+			}{},
+			wantOut: nil,
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			b := New(test.opts...)
+			b.configMap = test.config
+
+			err := b.decode(test.target, "")
+			if (err != nil) != test.wantErr {
+				t.Errorf(failTestMessage("decode", test.wantErr, err))
+			}
+
+			if !test.wantErr && !reflect.DeepEqual(test.target, test.wantOut) {
+				t.Errorf(failTestMessage("decode", test.wantOut, test.target))
+			}
+		})
+	}
+}
+
+func TestDecodeDefaults(t *testing.T) {
+	t.Parallel()
+
+	type nested struct {
+		Timeout time.Duration `config:"timeout" default:"5s"`
+	}
+
+	type target struct {
+		Host    string            `config:"host"    default:"localhost"`
+		Port    int               `config:"port"    default:"8080"`
+		URL     *url.URL          `config:"url"     default:"https://example.com"`
+		Tags    []string          `config:"tags"    default:"a b"`
+		Labels  map[string]string `config:"labels"  default:"team:x"`
+		Nested  nested            `config:"nested"`
+		NoValue string            `config:"no_value"`
+	}
+
+	tests := []struct {
+		name       string
+		config     map[string]string
+		target     any
+		wantOut    any
+		wantFailed []string
+	}{
+		{
+			name:   "When keys are missing then defaults should be used",
+			config: map[string]string{},
+			target: &target{},
+			wantOut: &target{
+				Host:   "localhost",
+				Port:   8080,
+				URL:    toURL("https://example.com"),
+				Tags:   []string{"a", "b"},
+				Labels: map[string]string{"team": "x"},
+				Nested: nested{Timeout: 5 * time.Second},
+			},
+			wantFailed: nil,
+		},
+		{
+			name: "When keys are present then defaults should be ignored",
+			config: map[string]string{
+				"host":           "example.com",
+				"port":           "",
+				"tags.0":         "c",
+				"labels.env":     "prod",
+				"nested.timeout": "1m",
+			},
+			target: &target{},
+			wantOut: &target{
+				Host:   "example.com",
+				URL:    toURL("https://example.com"),
+				Tags:   []string{"c"},
+				Labels: map[string]string{"env": "prod"},
+				Nested: nested{Timeout: time.Minute},
+			},
+			wantFailed: []string{`port (Port): strconv.ParseInt: parsing "": invalid syntax`},
+		},
+		{
+			name:   "When a default is invalid then it should be reported with the field path",
+			config: map[string]string{},
+			target: &struct {
+				Server struct {
+					Port int `config:"port" default:"eighty"`
+				} `config:"server"`
+			}{},
+			wantOut: nil,
+			wantFailed: []string{
+				`server.port (Server.Port): invalid default: strconv.ParseInt: parsing "eighty": invalid syntax`,
+			},
+		},
+		{
+			name:   "When a default of a slice element is invalid then it should be reported with the indexed path",
+			config: map[string]string{"servers.0.host": "a"},
+			target: &struct {
+				Servers []struct {
+					Host string `config:"host"`
+					Port int    `config:"port" default:"-"`
+				} `config:"servers"`
+			}{},
+			wantOut: nil,
+			wantFailed: []string{
+				`servers.0.port (Servers[0].Port): invalid default: strconv.ParseInt: parsing "-": invalid syntax`,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			b := New()
+			b.configMap = test.config
+
+			err := b.decode(test.target, "")
+
+			if got := fieldErrorStrings(decodeErrorFields(err)); !reflect.DeepEqual(got, test.wantFailed) {
+				t.Errorf(failTestMessage("decode", test.wantFailed, got))
+			}
+
+			if test.wantOut != nil && !reflect.DeepEqual(test.target, test.wantOut) {
+				t.Errorf(failTestMessage("decode", test.wantOut, test.target))
+			}
+		})
+	}
+}
+
+func TestDecodeRequired(t *testing.T) {
+	t.Parallel()
+
+	type database struct {
+		Host     string   `config:"host,required"`
+		Password string   `config:"password" required:"true"`
+		Port     int      `config:"port"     required:"true" default:"5432"`
+		Replicas []string `config:"replicas,required"`
+		Optional string   `config:"optional" required:"false"`
+	}
+
+	tests := []struct {
+		name       string
+		config     map[string]string
+		prefix     string
+		wantFailed []string
+	}{
+		{
+			name: "When required keys are present then no error should be reported",
+			config: map[string]string{
+				"host":       "localhost",
+				"password":   "",
+				"replicas.0": "replica",
+			},
+			prefix:     "",
+			wantFailed: nil,
+		},
+		{
+			name:   "When required keys are missing then all of them should be reported at once",
+			config: map[string]string{},
+			prefix: "",
+			wantFailed: []string{
+				"host (Host): missing required value",
+				"password (Password): missing required value",
+				"replicas (Replicas): missing required value",
+			},
+		},
+		{
+			name:   "When decoding a sub struct then the reported keys should include the prefix",
+			config: map[string]string{"db.host": "localhost", "db.replicas": "a b"},
+			prefix: "db",
+			wantFailed: []string{
+				"db.password (Password): missing required value",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			b := New()
+			b.configMap = test.config
+
+			var target database
+
+			var err error
+			if test.prefix == "" {
+				err = b.MapTo(&target)
+			} else {
+				err = b.Sub(&target, test.prefix)
+			}
+
+			if (err != nil) != (len(test.wantFailed) > 0) {
+				t.Errorf(failTestMessage("decode", test.wantFailed, err))
+			}
+
+			if got := fieldErrorStrings(decodeErrorFields(err)); !reflect.DeepEqual(got, test.wantFailed) {
+				t.Errorf(failTestMessage("decode", test.wantFailed, got))
+			}
+
+			if len(test.wantFailed) > 0 && !errors.Is(err, ErrMissingRequired) {
+				t.Errorf(failTestMessage("decode", ErrMissingRequired, err))
+			}
+		})
+	}
+}
+
+func TestDecodeFailuresPerDecode(t *testing.T) {
+	t.Parallel()
+
+	type database struct {
+		Port int `config:"port"`
+	}
+
+	type target struct {
+		Name     string   `config:"name,required"`
+		Database database `config:"db"`
+	}
+
+	b := New().From(NewMapSource("values", map[string]string{"db.port": "x"}))
+	b.addFailure(FieldError{Source: "broken", Cause: errors.New("unreachable")})
+
+	var sub database
+	_ = b.Sub(&sub, "db")
+
+	want := []string{
+		`broken: unreachable`,
+		`db.port (Database.Port) from values: strconv.ParseInt: parsing "x": invalid syntax`,
+		`name (Name): missing required value`,
+	}
+
+	for range 2 {
+		var got target
+
+		if fields := fieldErrorStrings(decodeErrorFields(b.MapTo(&got))); !reflect.DeepEqual(fields, want) {
+			t.Errorf(failTestMessage("MapTo", want, fields))
+		}
+	}
+}
+
+func TestDecodeTextTypes(t *testing.T) {
+	t.Parallel()
+
+	type target struct {
+		Level    slog.Level             `config:"level"`
+		Override *slog.Level            `config:"override"`
+		Levels   []slog.Level           `config:"levels"`
+		Addr     netip.Addr             `config:"addr"`
+		IP       net.IP                 `config:"ip"`
+		IPs      []net.IP               `config:"ips"`
+		ByName   map[string]slog.Level  `config:"by_name"`
+		Default  netip.Prefix           `config:"default" default:"10.0.0.0/8"`
+		Unset    *slog.Level            `config:"unset"`
+		Nested   map[string]*netip.Addr `config:"nested"`
+	}
+
+	b := New()
+	b.mergeValues(map[string]string{
+		"level":        "warn",
+		"override":     "error",
+		"levels":       "debug info",
+		"addr":         "::1",
+		"ip":           "10.0.0.1",
+		"ips.0":        "10.0.0.2",
+		"ips.1":        "10.0.0.3",
+		"by_name":      "api:debug",
+		"nested.proxy": "10.0.0.4",
+	}, nil, Origin{}, nil)
+
+	var got target
+	if err := b.MapTo(&got); err != nil {
+		t.Fatal(err)
+	}
+
+	override := slog.LevelError
+	proxy := netip.MustParseAddr("10.0.0.4")
+	want := target{
+		Level:    slog.LevelWarn,
+		Override: &override,
+		Levels:   []slog.Level{slog.LevelDebug, slog.LevelInfo},
+		Addr:     netip.MustParseAddr("::1"),
+		IP:       net.ParseIP("10.0.0.1"),
+		IPs:      []net.IP{net.ParseIP("10.0.0.2"), net.ParseIP("10.0.0.3")},
+		ByName:   map[string]slog.Level{"api": slog.LevelDebug},
+		Default:  netip.MustParsePrefix("10.0.0.0/8"),
+		Nested:   map[string]*netip.Addr{"proxy": &proxy},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf(failTestMessage("MapTo", want, got))
+	}
+}
+
+func TestDecodeTimeLayout(t *testing.T) {
+	t.Parallel()
+
+	type target struct {
+		Date     time.Time            `config:"date" layout:"2006-01-02"`
+		Deadline *time.Time           `config:"deadline" layout:"02/01/2006 15:04"`
+		Holidays []time.Time          `config:"holidays" layout:"2006-01-02"`
+		ByRegion map[string]time.Time `config:"by_region" layout:"2006-01-02"`
+		Start    time.Time            `config:"start" layout:"2006-01-02" default:"2024-01-01"`
+		Created  time.Time            `config:"created"`
+		Zone     *time.Location       `config:"zone" default:"UTC"`
+	}
+
+	b := New()
+	b.mergeValues(map[string]string{
+		"date":         "2024-03-15",
+		"deadline":     "31/12/2024 18:30",
+		"holidays":     "2024-12-25 2024-12-26",
+		"by_region.eu": "2024-05-01",
+		"created":      "2024-03-15T10:00:00Z",
+	}, nil, Origin{}, nil)
+
+	var got target
+	if err := b.MapTo(&got); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Date(2024, 12, 31, 18, 30, 0, 0, time.UTC)
+	want := target{
+		Date:     time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC),
+		Deadline: &deadline,
+		Holidays: []time.Time{time.Date(2024, 12, 25, 0, 0, 0, 0, time.UTC), time.Date(2024, 12, 26, 0, 0, 0, 0, time.UTC)},
+		ByRegion: map[string]time.Time{"eu": time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)},
+		Start:    time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		Created:  time.Date(2024, 3, 15, 10, 0, 0, 0, time.UTC),
+		Zone:     time.UTC,
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf(failTestMessage("MapTo", want, got))
+	}
+
+	b.mergeValues(map[string]string{"date": "2024-03-15T10:00:00Z"}, nil, Origin{}, nil)
+
+	var decodeErr *DecodeError
+	if err := b.MapTo(&got); !errors.As(err, &decodeErr) || len(decodeErr.Fields) != 1 ||
+		decodeErr.Fields[0].Key != "date" {
+		t.Errorf(failTestMessage("MapTo", "an error for date in the wrong layout", err))
+	}
+}
+
+func TestDecodeEnvEscapes(t *testing.T) {
+	t.Parallel()
+
+	type target struct {
+		Pass    string `config:"pass"`
+		Escaped string `config:"escaped"`
+		URL     string `config:"url"`
+	}
+
+	b := New()
+	b.mergeValues(map[string]string{"pass": "file", "escaped": "pa$$word"}, nil, Origin{Source: SourceDotenv}, nil)
+	b.mergeValues(map[string]string{"pass": "pa$$word", "url": "db://${escaped}:${pass}"}, nil,
+		Origin{Source: SourceEnv}, nil)
+
+	var got target
+	if err := b.MapTo(&got); err != nil {
+		t.Fatal(err)
+	}
+
+	want := target{Pass: "pa$$word", Escaped: "pa$word", URL: "db://pa$word:pa$$word"}
+
+	if got != want {
+		t.Errorf(failTestMessage("MapTo", want, got))
+	}
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"reflect"
+
+	"github.com/pkg/errors"
+)
+
+// decodeFunc converts a config value to a value of the type it is registered for.
+type decodeFunc func(str string) (reflect.Value, error)
+
+// decoders holds the decoders registered on a builder with WithDecoder, by type.
+type decoders map[reflect.Type]decodeFunc
+
+// has returns true if a decoder is registered for the type.
+func (d decoders) has(t reflect.Type) bool {
+	_, ok := d[t]
+
+	return ok
+}
+
+// WithDecoder registers a function that converts config values to T, for types that cannot implement
+// encoding.TextUnmarshaler themselves, such as third-party types. The decoder takes precedence over
+// the built-in conversions, and is used wherever T is: in fields, pointers, slices, map keys and values,
+// secrets and defaults. Registering a decoder for the same type again replaces it. Datetimes that a source
+// decoded itself, such as those of TOML, are set as they are rather than decoded from their text form.
+//
+// Decoders are registered on the builder only, so that builders, such as those of parallel tests,
+// never see each other's decoders. It panics if decode is nil.
+//
+// Example:
+//
+//	builder := config.New(config.WithDecoder(uuid.Parse), config.WithDecoder(decimal.NewFromString))
+func WithDecoder[T any](decode func(str string) (T, error)) Option {
+	if decode == nil {
+		panic("config: decoder must not be nil")
+	}
+
+	return func(b *Builder) {
+		if b.decoders == nil {
+			b.decoders = make(decoders)
+		}
+
+		b.decoders[reflect.TypeFor[T]()] = func(str string) (reflect.Value, error) {
+			val, err := decode(str)
+
+			// Take the value through a pointer, so that a nil interface or pointer keeps its type.
+			return reflect.ValueOf(&val).Elem(), err
+		}
+	}
+}
+
+// convertAndSetDecoded converts a string with a registered decoder and sets the result on the reflect.Value.
+func convertAndSetDecoded(decode decodeFunc, settableValue reflect.Value, str string) error {
+	val, err := decode(str)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	settableValue.Set(val)
+
+	return nil
+}
+
+// isWholeValue returns true for slice and map types that are converted from a single value, because they
+// parse their own text form, such as net.IP, or have a registered decoder, rather than element by element.
+func (b *Builder) isWholeValue(t reflect.Type) bool {
+	return isTextType(t) || b.decoders.has(t)
+}
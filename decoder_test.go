@@ -0,0 +1,153 @@
+package config
+
+import (
+	"errors"
+	"log/slog"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// testMoney stands for a third-party type with unexported fields and no text methods, such as a decimal.
+type testMoney struct {
+	cents int64
+}
+
+func parseTestMoney(str string) (testMoney, error) {
+	units, cents, _ := strings.Cut(str, ".")
+
+	value, err := strconv.ParseInt(units+cents, 10, 64)
+	if err != nil {
+		return testMoney{}, errors.New("invalid amount")
+	}
+
+	return testMoney{cents: value}, nil
+}
+
+// testID stands for a third-party type whose kind is a slice, such as a byte-based identifier.
+type testID []byte
+
+func TestWithDecoder(t *testing.T) {
+	t.Parallel()
+
+	type target struct {
+		Price    testMoney            `config:"price"`
+		Discount *testMoney           `config:"discount"`
+		Prices   []testMoney          `config:"prices"`
+		ByItem   map[string]testMoney `config:"by_item"`
+		Fee      testMoney            `config:"fee" default:"0.50"`
+		Budget   Secret[testMoney]    `config:"budget"`
+		ID       testID               `config:"id"`
+		Level    slog.Level           `config:"level"`
+	}
+
+	b := New(
+		WithDecoder(parseTestMoney),
+		WithDecoder(func(str string) (testID, error) { return testID(str), nil }),
+		WithDecoder(func(str string) (slog.Level, error) { return slog.Level(len(str)), nil }),
+	)
+	b.mergeValues(map[string]string{
+		"price":       "1.99",
+		"discount":    "0.10",
+		"prices":      "1.00 2.00",
+		"by_item.tea": "3.50",
+		"budget":      "100.00",
+		"id":          "a b",
+		"level":       "warn",
+	}, map[string]any{"price": float64(1.99)}, Origin{}, nil)
+
+	var got target
+	if err := b.MapTo(&got); err != nil {
+		t.Fatal(err)
+	}
+
+	want := target{
+		Price:    testMoney{cents: 199},
+		Discount: &testMoney{cents: 10},
+		Prices:   []testMoney{{cents: 100}, {cents: 200}},
+		ByItem:   map[string]testMoney{"tea": {cents: 350}},
+		Fee:      testMoney{cents: 50},
+		Budget:   NewSecret(testMoney{cents: 10000}),
+		ID:       testID("a b"),
+		Level:    slog.Level(4),
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf(failTestMessage("MapTo", want, got))
+	}
+}
+
+func TestWithDecoderErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		opts    []Option
+		target  any
+		wantErr string
+	}{
+		{
+			name: "When the decoder fails then its error should be reported",
+			opts: []Option{WithDecoder(parseTestMoney)},
+			target: &struct {
+				Price testMoney `config:"price"`
+			}{},
+			wantErr: "price (Price): invalid amount",
+		},
+		{
+			name: "When the decoder is registered on another builder then it should not be used",
+			opts: nil,
+			target: &struct {
+				ID testID `config:"price"`
+			}{},
+			wantErr: `price (ID[0]): strconv.ParseUint: parsing "free": invalid syntax`,
+		},
+	}
+
+	New(WithDecoder(func(str string) (testID, error) { return testID(str), nil }))
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			b := New(test.opts...)
+			b.mergeValues(map[string]string{"price": "free"}, nil, Origin{}, nil)
+
+			var decodeErr *DecodeError
+			if err := b.MapTo(test.target); !errors.As(err, &decodeErr) || len(decodeErr.Fields) != 1 {
+				t.Fatalf(failTestMessage("MapTo", test.wantErr, err))
+			}
+
+			if got := decodeErr.Fields[0].Error(); got != test.wantErr {
+				t.Errorf(failTestMessage("MapTo", test.wantErr, got))
+			}
+		})
+	}
+}
+
+func TestWithDecoderRebuild(t *testing.T) {
+	t.Parallel()
+
+	b := New(WithDecoder(parseTestMoney)).From(NewMapSource("defaults", map[string]string{"price": "2.50"}))
+
+	var got struct {
+		Price testMoney `config:"price"`
+	}
+
+	if err := b.rebuild().MapTo(&got); err != nil || got.Price.cents != 250 {
+		t.Errorf(failTestMessage("rebuild", 250, err))
+	}
+}
+
+func TestWithDecoderPanics(t *testing.T) {
+	t.Parallel()
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf(failTestMessage("WithDecoder", "a panic", nil))
+		}
+	}()
+
+	WithDecoder[testMoney](nil)
+}
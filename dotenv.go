@@ -0,0 +1,318 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	dotenvComment      = '#'
+	dotenvSingleQuote  = '\''
+	dotenvDoubleQuote  = '"'
+	dotenvEscape       = '\\'
+	dotenvExportPrefix = "export"
+)
+
+// dotenvError describes a syntax error in a dotenv file, positioned at the line where it occurred.
+type dotenvError struct {
+	file string
+	line int
+	msg  string
+}
+
+func (e dotenvError) Error() string {
+	return fmt.Sprintf("%s:%d: %s", e.file, e.line, e.msg)
+}
+
+// dotenvParser is a small hand-written lexer for the dotenv format.
+// Supported syntax:
+//   - Blank lines and lines starting with '#' are ignored.
+//   - An optional "export " prefix before the key.
+//   - Unquoted values, trimmed of whitespace, with inline comments introduced by " #".
+//   - Single-quoted values, taken literally, which may span multiple lines.
+//   - Double-quoted values with escape sequences (\n, \r, \t, \\, \", \$), which may span multiple lines.
+//
+// Since values are interpolated after all sources are merged, a '$' inside single quotes or escaped as \$
+// is stored as "$$", so that it is kept literally.
+//
+// Keys are kept as written, just like keyValsToMap does for environment variables, and normalized
+// when they are merged into the config map.
+type dotenvParser struct {
+	file string
+	src  string
+	pos  int
+	line int
+}
+
+// parseDotenv parses the content of a dotenv file and returns its key-value pairs, along with the line
+// where each key is defined. Keys are kept as written, and normalized when they are merged into the config map.
+// Syntax errors do not stop the parser: the offending line is skipped and the error is collected,
+// except for an unterminated quoted value, which consumes the rest of the file.
+//
+// Example:
+//
+//	values, lines, errs := parseDotenv(".env", "export HOST=localhost # inline comment\nKEY='a b'")
+//	fmt.Println(values, lines, errs) // Output: map[HOST:localhost KEY:a b] map[HOST:1 KEY:2] []
+func parseDotenv(file string, content string) (map[string]string, map[string]int, []dotenvError) {
+	p := &dotenvParser{
+		file: file,
+		src:  strings.ReplaceAll(content, "\r\n", "\n"),
+		line: 1,
+	}
+
+	values := make(map[string]string)
+	lines := make(map[string]int)
+
+	var errs []dotenvError
+
+	for !p.eof() {
+		line := p.line
+		key, val, err := p.parseLine()
+
+		if err != nil {
+			errs = append(errs, *err)
+			p.skipLine()
+
+			continue
+		}
+
+		if key != "" {
+			values[key] = val
+			lines[key] = line
+		}
+	}
+
+	return values, lines, errs
+}
+
+// parseLine parses a single logical line, which may span multiple physical lines when the value is quoted.
+// It returns an empty key for blank and comment lines.
+func (p *dotenvParser) parseLine() (string, string, *dotenvError) {
+	p.skipBlanks()
+
+	if p.eof() {
+		return "", "", nil
+	}
+
+	switch p.peek() {
+	case '\n':
+		p.next()
+
+		return "", "", nil
+	case dotenvComment:
+		p.skipLine()
+
+		return "", "", nil
+	}
+
+	startLine := p.line
+	key := p.readKey()
+
+	if key == dotenvExportPrefix && p.peekBlank() {
+		p.skipBlanks()
+		key = p.readKey()
+	}
+
+	if key == "" {
+		return "", "", p.errorf(startLine, "expected a key, found %q", p.peek())
+	}
+
+	p.skipBlanks()
+
+	if p.eof() || p.peek() != '=' {
+		return "", "", p.errorf(startLine, "expected '=' after key %q", key)
+	}
+
+	p.next() // skip '='
+	p.skipBlanks()
+
+	var (
+		val string
+		err *dotenvError
+	)
+
+	switch {
+	case p.eof():
+	case p.peek() == dotenvSingleQuote:
+		val, err = p.readSingleQuoted(startLine)
+	case p.peek() == dotenvDoubleQuote:
+		val, err = p.readDoubleQuoted(startLine)
+	default:
+		val = p.readUnquoted()
+	}
+
+	if err != nil {
+		return "", "", err
+	}
+
+	if err := p.expectLineEnd(); err != nil {
+		return "", "", err
+	}
+
+	return key, val, nil
+}
+
+// readKey reads a key made of letters, digits, '_', '.' and '-'.
+func (p *dotenvParser) readKey() string {
+	start := p.pos
+
+	for !p.eof() && isDotenvKeyChar(p.peek()) {
+		p.next()
+	}
+
+	return p.src[start:p.pos]
+}
+
+// readUnquoted reads a value up to the end of the line or an inline comment, trimmed of whitespace.
+func (p *dotenvParser) readUnquoted() string {
+	start := p.pos
+
+	for !p.eof() && p.peek() != '\n' {
+		if p.peek() == dotenvComment && isDotenvBlank(p.src[p.pos-1]) {
+			break
+		}
+
+		p.next()
+	}
+
+	return strings.TrimSpace(p.src[start:p.pos])
+}
+
+// readSingleQuoted reads a value enclosed in single quotes. The content is taken literally.
+func (p *dotenvParser) readSingleQuoted(startLine int) (string, *dotenvError) {
+	p.next() // skip opening quote
+	start := p.pos
+
+	for !p.eof() && p.peek() != dotenvSingleQuote {
+		p.next()
+	}
+
+	if p.eof() {
+		return "", p.errorf(startLine, "unterminated single-quoted value")
+	}
+
+	val := strings.ReplaceAll(p.src[start:p.pos], "$", interpolationEscape)
+	p.next() // skip closing quote
+
+	return val, nil
+}
+
+// readDoubleQuoted reads a value enclosed in double quotes, resolving escape sequences.
+func (p *dotenvParser) readDoubleQuoted(startLine int) (string, *dotenvError) {
+	p.next() // skip opening quote
+
+	var sb strings.Builder
+
+	for !p.eof() && p.peek() != dotenvDoubleQuote {
+		c := p.next()
+
+		if c != dotenvEscape || p.eof() {
+			sb.WriteByte(c)
+
+			continue
+		}
+
+		switch e := p.next(); e {
+		case 'n':
+			sb.WriteByte('\n')
+		case 'r':
+			sb.WriteByte('\r')
+		case 't':
+			sb.WriteByte('\t')
+		case dotenvEscape, dotenvDoubleQuote:
+			sb.WriteByte(e)
+		case '$':
+			sb.WriteString(interpolationEscape)
+		default:
+			// Unknown escape sequences are kept as they are.
+			sb.WriteByte(c)
+			sb.WriteByte(e)
+		}
+	}
+
+	if p.eof() {
+		return "", p.errorf(startLine, "unterminated double-quoted value")
+	}
+
+	p.next() // skip closing quote
+
+	return sb.String(), nil
+}
+
+// expectLineEnd makes sure only whitespace or a comment follows a quoted value.
+func (p *dotenvParser) expectLineEnd() *dotenvError {
+	p.skipBlanks()
+
+	if p.eof() {
+		return nil
+	}
+
+	switch p.peek() {
+	case '\n':
+		p.next()
+	case dotenvComment:
+		p.skipLine()
+	default:
+		return p.errorf(p.line, "unexpected character %q after value", p.peek())
+	}
+
+	return nil
+}
+
+func (p *dotenvParser) errorf(line int, format string, args ...any) *dotenvError {
+	return &dotenvError{
+		file: p.file,
+		line: line,
+		msg:  fmt.Sprintf(format, args...),
+	}
+}
+
+// skipLine moves the position past the next newline.
+func (p *dotenvParser) skipLine() {
+	for !p.eof() {
+		if p.next() == '\n' {
+			return
+		}
+	}
+}
+
+func (p *dotenvParser) skipBlanks() {
+	for p.peekBlank() {
+		p.next()
+	}
+}
+
+func (p *dotenvParser) peekBlank() bool {
+	return !p.eof() && isDotenvBlank(p.peek())
+}
+
+func (p *dotenvParser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *dotenvParser) peek() byte {
+	return p.src[p.pos]
+}
+
+// next returns the current character and advances the position, keeping track of line numbers.
+func (p *dotenvParser) next() byte {
+	c := p.src[p.pos]
+	p.pos++
+
+	if c == '\n' {
+		p.line++
+	}
+
+	return c
+}
+
+func isDotenvBlank(c byte) bool {
+	return c == ' ' || c == '\t'
+}
+
+func isDotenvKeyChar(c byte) bool {
+	return c >= 'a' && c <= 'z' ||
+		c >= 'A' && c <= 'Z' ||
+		c >= '0' && c <= '9' ||
+		c == '_' || c == '.' || c == '-'
+}
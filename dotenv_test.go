@@ -0,0 +1,141 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden files in testdata")
+
+// formatDotenv renders the result of parseDotenv in a stable, line-oriented format used by the golden files.
+func formatDotenv(values map[string]string, lines map[string]int, errs []dotenvError) string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	var sb strings.Builder
+
+	for _, key := range keys {
+		fmt.Fprintf(&sb, "%d: %s=%q\n", lines[key], key, values[key])
+	}
+
+	for _, err := range errs {
+		fmt.Fprintf(&sb, "error: %s\n", err.Error())
+	}
+
+	return sb.String()
+}
+
+func TestParseDotenvGolden(t *testing.T) {
+	t.Parallel()
+
+	files, err := filepath.Glob(filepath.Join("testdata", "dotenv", "*.env"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) == 0 {
+		t.Fatal("no golden test cases found")
+	}
+
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			t.Parallel()
+
+			content, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := formatDotenv(parseDotenv(filepath.Base(file), string(content)))
+			goldenFile := strings.TrimSuffix(file, ".env") + ".golden"
+
+			if *updateGolden {
+				if err := os.WriteFile(goldenFile, []byte(got), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			want, err := os.ReadFile(goldenFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got != string(want) {
+				t.Errorf(failTestMessage("parseDotenv", string(want), got))
+			}
+		})
+	}
+}
+
+func TestParseDotenv(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		content  string
+		want     map[string]string
+		wantErrs []string
+	}{
+		{
+			name:     "When content is empty then the map should be empty",
+			content:  "",
+			want:     map[string]string{},
+			wantErrs: nil,
+		},
+		{
+			name:     "When last line has no trailing newline then it should still be parsed",
+			content:  "KEY=value",
+			want:     map[string]string{"KEY": "value"},
+			wantErrs: nil,
+		},
+		{
+			name:     "When key is repeated then the last value should win",
+			content:  "KEY=first\nKEY=second\n",
+			want:     map[string]string{"KEY": "second"},
+			wantErrs: nil,
+		},
+		{
+			name:     "When export is used as a key then it should not be treated as a prefix",
+			content:  "export=value\n",
+			want:     map[string]string{"export": "value"},
+			wantErrs: nil,
+		},
+		{
+			name:     "When a line is invalid then the error should carry the file and line",
+			content:  "KEY=value\n\nINVALID\n",
+			want:     map[string]string{"KEY": "value"},
+			wantErrs: []string{`.env:3: expected '=' after key "INVALID"`},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, _, errs := parseDotenv(".env", test.content)
+
+			var gotErrs []string
+			for _, err := range errs {
+				gotErrs = append(gotErrs, err.Error())
+			}
+
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf(failTestMessage("parseDotenv", test.want, got))
+			}
+
+			if !reflect.DeepEqual(gotErrs, test.wantErrs) {
+				t.Errorf(failTestMessage("parseDotenv", test.wantErrs, gotErrs))
+			}
+		})
+	}
+}
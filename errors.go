@@ -0,0 +1,122 @@
+package config
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrMissingRequired is the cause of a FieldError for a required field without a value.
+	ErrMissingRequired = errors.New("missing required value")
+	// ErrMissingListElement is the cause of a FieldError for a gap in the indices of a list.
+	ErrMissingListElement = errors.New("missing list element")
+	// ErrKeyCollision is the cause of a FieldError for a key that several keys of a source collapse to
+	// once normalized, such as DATABASE_HOST and database_host.
+	ErrKeyCollision = errors.New("keys collapse to the same normalized key")
+	// ErrInvalidValue is the cause of a FieldError for a value that breaks a rule of its `validate` tag.
+	ErrInvalidValue = errors.New("invalid value")
+)
+
+// FieldError describes a single failure found while building or decoding the config.
+// Errors about a whole source, such as a file that cannot be read, have no Key.
+type FieldError struct {
+	// Key is the config key that failed, e.g. server.port.
+	Key string
+	// FieldPath is the Go path of the field that the key maps to, e.g. Server.Port or Servers[0].Port.
+	FieldPath string
+	// Source is the origin of the failing value or of the error, e.g. "dotenv .env:3", as returned by Origin.String.
+	// It is empty for keys that no source set, such as a missing required value.
+	Source string
+	// RawValue is the value of the key before conversion.
+	RawValue string
+	// Cause is the underlying error, such as a *strconv.NumError or ErrMissingRequired.
+	Cause error
+}
+
+// Error returns the failure on a single line, e.g. server.port (Server.Port) from env: strconv.ParseInt: ...
+func (e FieldError) Error() string {
+	var sb strings.Builder
+
+	switch {
+	case e.Key != "":
+		sb.WriteString(e.Key)
+
+		if e.FieldPath != "" {
+			sb.WriteString(" (" + e.FieldPath + ")")
+		}
+
+		if e.Source != "" {
+			sb.WriteString(" from " + e.Source)
+		}
+	case e.Source != "":
+		sb.WriteString(e.Source)
+	default:
+		sb.WriteString("config")
+	}
+
+	if e.Cause != nil {
+		sb.WriteString(": " + e.Cause.Error())
+	}
+
+	return sb.String()
+}
+
+// Unwrap returns the cause, so that errors.Is and errors.As reach it.
+func (e FieldError) Unwrap() error {
+	return e.Cause
+}
+
+// DecodeError is returned by MapTo and Sub when any source or field failed.
+// It holds every failure at once, sorted by key, so that they can all be fixed in one go.
+//
+// Example:
+//
+//	var decodeErr *config.DecodeError
+//	if errors.As(err, &decodeErr) {
+//	  for _, fieldErr := range decodeErr.Fields {
+//	    log.Printf("%s: %v", fieldErr.Key, fieldErr.Cause)
+//	  }
+//	}
+type DecodeError struct {
+	Fields []FieldError
+}
+
+// Error returns one line per failure, below a summary line.
+func (e *DecodeError) Error() string {
+	var sb strings.Builder
+
+	sb.WriteString("config: the following fields had errors:")
+
+	for _, fieldErr := range e.Fields {
+		sb.WriteString("\n  - " + fieldErr.Error())
+	}
+
+	return sb.String()
+}
+
+// Unwrap returns the field errors, so that errors.Is and errors.As reach any of them and their causes.
+func (e *DecodeError) Unwrap() []error {
+	errs := make([]error, len(e.Fields))
+	for i, fieldErr := range e.Fields {
+		errs[i] = fieldErr
+	}
+
+	return errs
+}
+
+// sortFieldErrors sorts field errors by key, then by source and message, for deterministic output.
+func sortFieldErrors(fieldErrs []FieldError) {
+	sort.SliceStable(fieldErrs, func(i, j int) bool {
+		if fieldErrs[i].Key != fieldErrs[j].Key {
+			return fieldErrs[i].Key < fieldErrs[j].Key
+		}
+
+		if fieldErrs[i].Source != fieldErrs[j].Source {
+			return fieldErrs[i].Source < fieldErrs[j].Source
+		}
+
+		return fieldErrs[i].Error() < fieldErrs[j].Error()
+	})
+}
@@ -0,0 +1,109 @@
+package config
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func TestDecodeError(t *testing.T) {
+	t.Parallel()
+
+	type target struct {
+		Port     int    `config:"port"`
+		Host     string `config:"host,required"`
+		Replicas []int  `config:"replicas"`
+	}
+
+	b := New()
+	b.configMap = map[string]string{"port": "eighty", "replicas": "1 two"}
+
+	err := b.MapTo(&target{})
+
+	var decodeErr *DecodeError
+	if !errors.As(err, &decodeErr) {
+		t.Fatalf(failTestMessage("MapTo", "*DecodeError", err))
+	}
+
+	wantKeys := []string{"host", "port", "replicas"}
+	if len(decodeErr.Fields) != len(wantKeys) {
+		t.Fatalf(failTestMessage("MapTo", wantKeys, decodeErr.Fields))
+	}
+
+	for i, key := range wantKeys {
+		if decodeErr.Fields[i].Key != key {
+			t.Errorf(failTestMessage("MapTo", key, decodeErr.Fields[i].Key))
+		}
+	}
+
+	if got := decodeErr.Fields[1]; got.FieldPath != "Port" || got.RawValue != "eighty" {
+		t.Errorf(failTestMessage("MapTo", "Port eighty", got.FieldPath+" "+got.RawValue))
+	}
+
+	if got := decodeErr.Fields[2]; got.FieldPath != "Replicas[1]" || got.RawValue != "two" {
+		t.Errorf(failTestMessage("MapTo", "Replicas[1] two", got.FieldPath+" "+got.RawValue))
+	}
+
+	var numErr *strconv.NumError
+	if !errors.As(err, &numErr) || numErr.Num != "eighty" {
+		t.Errorf(failTestMessage("MapTo", "*strconv.NumError", err))
+	}
+
+	if !errors.Is(err, ErrMissingRequired) {
+		t.Errorf(failTestMessage("MapTo", ErrMissingRequired, err))
+	}
+
+	want := "config: the following fields had errors:\n" +
+		"  - host (Host): missing required value\n" +
+		"  - port (Port): strconv.ParseInt: parsing \"eighty\": invalid syntax\n" +
+		"  - replicas (Replicas[1]): strconv.ParseInt: parsing \"two\": invalid syntax"
+	if err.Error() != want {
+		t.Errorf(failTestMessage("Error", want, err.Error()))
+	}
+}
+
+func TestFieldErrorError(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		fieldErr FieldError
+		want     string
+	}{
+		{
+			name:     "When the error has a key and a path then both should be shown",
+			fieldErr: FieldError{Key: "server.port", FieldPath: "Server.Port", Cause: ErrMissingRequired},
+			want:     "server.port (Server.Port): missing required value",
+		},
+		{
+			name:     "When the error has no path then only the key should be shown",
+			fieldErr: FieldError{Key: "server.port", Cause: ErrMissingRequired},
+			want:     "server.port: missing required value",
+		},
+		{
+			name: "When the error has a key and a source then the source should follow the key",
+			fieldErr: FieldError{
+				Key:       "server.port",
+				FieldPath: "Server.Port",
+				Source:    "dotenv .env:3",
+				Cause:     ErrMissingListElement,
+			},
+			want: "server.port (Server.Port) from dotenv .env:3: missing list element",
+		},
+		{
+			name:     "When the error has no key then the source should be shown",
+			fieldErr: FieldError{Source: "dotenv .env:3", Cause: errors.New("parse: unterminated quoted value")},
+			want:     "dotenv .env:3: parse: unterminated quoted value",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := test.fieldErr.Error(); got != test.want {
+				t.Errorf(failTestMessage("Error", test.want, got))
+			}
+		})
+	}
+}
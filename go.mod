@@ -3,8 +3,10 @@ module github.com/vnworkday/config
 go 1.22
 
 require (
+	github.com/BurntSushi/toml v1.4.0
 	github.com/pkg/errors v0.9.1
 	golang.org/x/tools v0.23.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
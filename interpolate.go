@@ -0,0 +1,376 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	interpolationEscape = "$$"
+	interpolationOpen   = "${"
+	interpolationClose  = '}'
+)
+
+// interpolationError describes why the value of a key could not be expanded.
+type interpolationError struct {
+	msg   string
+	cause error
+}
+
+func (e *interpolationError) Error() string {
+	return e.msg
+}
+
+func (e *interpolationError) Unwrap() error {
+	return e.cause
+}
+
+// interpolator expands shell-style references in the values of a map, resolving them against the same map.
+// Supported syntax:
+//   - ${VAR}: the value of VAR, or an empty string if VAR is not set.
+//   - ${VAR:-default}: the value of VAR, or default if VAR is not set or empty.
+//   - ${VAR-default}: the value of VAR, or default if VAR is not set.
+//   - ${VAR:?message}: the value of VAR, or an error with message if VAR is not set or empty.
+//   - ${VAR?message}: the value of VAR, or an error with message if VAR is not set.
+//   - $$: a literal '$'.
+//
+// A reference to the key it is the value of, as in PORT=${PORT:-8080}, is treated as not set.
+// A '$' that is not followed by '{' or '$' is kept as it is. Defaults may contain references themselves.
+// In the values of keys that keepEscapes reports, such as values from the process environment, "$$" is
+// kept as written rather than collapsed, so that a deployed value such as pa$$word is not altered.
+// References are looked up as written first, then normalized, since keys read from the environment
+// and from files are stored normalized, e.g. in lowercase.
+//
+// Keys are expanded lazily and memoized, so that a broken value only fails the fields that use it.
+// The keys that each expansion used are recorded, so that failures of values that include a secret
+// can be redacted.
+type interpolator struct {
+	values      map[string]string
+	normalize   KeyNormalizer
+	keepEscapes func(key string) bool
+	secrets     secretFileReader
+	resolved    map[string]string
+	failed      map[string]*interpolationError
+	refs        map[string][]string
+	stack       []string
+}
+
+// secretFileReader provides the values of keys held in secret files, which an interpolator uses as they are,
+// without expanding them. The keys need not be in the values, as for token when token_file is set.
+type secretFileReader interface {
+	// hasSecretFile returns true if the value of key is held in a secret file.
+	hasSecretFile(key string) bool
+	// readSecretFile returns the contents of the secret file that holds the value of key.
+	readSecretFile(key string) (string, error)
+}
+
+// newInterpolator creates an interpolator that resolves references against values.
+// KeepEscapes reports the keys whose values keep "$$" as written, and secrets provides the values held in
+// secret files; both may be nil.
+//
+// Example:
+//
+//	values := map[string]string{"host": "localhost", "url": "http://${HOST}:${PORT:-80}"}
+//	in := newInterpolator(values, LowerCaseKeys, nil, nil)
+//	url, err := in.resolve("url")
+//	fmt.Println(url, err) // Output: http://localhost:80 <nil>
+func newInterpolator(
+	values map[string]string,
+	normalize KeyNormalizer,
+	keepEscapes func(key string) bool,
+	secrets secretFileReader,
+) *interpolator {
+	return &interpolator{
+		values:      values,
+		normalize:   normalize,
+		keepEscapes: keepEscapes,
+		secrets:     secrets,
+		resolved:    make(map[string]string),
+		failed:      make(map[string]*interpolationError),
+		refs:        make(map[string][]string),
+	}
+}
+
+// references returns the keys whose values the expansion of key used, directly or through other references.
+func (in *interpolator) references(key string) []string {
+	return in.refs[key]
+}
+
+// resolve returns the expanded value of the key, memoizing both results and failures.
+func (in *interpolator) resolve(key string) (string, *interpolationError) {
+	if val, ok := in.resolved[key]; ok {
+		return val, nil
+	}
+
+	if err, ok := in.failed[key]; ok {
+		return "", err
+	}
+
+	for i, visiting := range in.stack {
+		if visiting == key {
+			cycle := append(append([]string{}, in.stack[i:]...), key)
+
+			return "", &interpolationError{msg: "cycle detected: " + strings.Join(cycle, " -> ")}
+		}
+	}
+
+	in.stack = append(in.stack, key)
+	val, err := in.value(key)
+	in.stack = in.stack[:len(in.stack)-1]
+
+	if err != nil {
+		in.failed[key] = err
+
+		return "", err
+	}
+
+	in.resolved[key] = val
+
+	return val, nil
+}
+
+// value returns the contents of the secret file that holds the value of key, if any, or else the expanded value
+// of key.
+func (in *interpolator) value(key string) (string, *interpolationError) {
+	if in.secrets == nil || !in.secrets.hasSecretFile(key) {
+		return in.expand(in.values[key])
+	}
+
+	content, err := in.secrets.readSecretFile(key)
+	if err != nil {
+		return "", &interpolationError{msg: err.Error(), cause: err}
+	}
+
+	return content, nil
+}
+
+// expandValue returns the expanded value of key, even if its value names a secret file, without memoizing it.
+// It is used for the path of a value with the file scheme, such as file://${SECRETS_DIR}/token.
+func (in *interpolator) expandValue(key string) (string, *interpolationError) {
+	if len(in.stack) == 0 || in.stack[len(in.stack)-1] != key {
+		in.stack = append(in.stack, key)
+		defer func() { in.stack = in.stack[:len(in.stack)-1] }()
+	}
+
+	return in.expand(in.values[key])
+}
+
+// expand replaces all references in str, which is a value or a default inside a reference.
+func (in *interpolator) expand(str string) (string, *interpolationError) {
+	var sb strings.Builder
+
+	for {
+		idx := strings.IndexByte(str, '$')
+
+		if idx < 0 {
+			sb.WriteString(str)
+
+			return sb.String(), nil
+		}
+
+		sb.WriteString(str[:idx])
+		str = str[idx:]
+
+		switch {
+		case strings.HasPrefix(str, interpolationEscape):
+			if in.keepingEscapes() {
+				sb.WriteString(interpolationEscape)
+			} else {
+				sb.WriteByte('$')
+			}
+
+			str = str[len(interpolationEscape):]
+		case strings.HasPrefix(str, interpolationOpen):
+			end := findClosingBrace(str[len(interpolationOpen):])
+
+			if end < 0 {
+				return "", &interpolationError{msg: fmt.Sprintf("unterminated reference in %q", str)}
+			}
+
+			expr := str[len(interpolationOpen) : len(interpolationOpen)+end]
+
+			val, err := in.expandReference(expr)
+			if err != nil {
+				return "", err
+			}
+
+			sb.WriteString(val)
+			str = str[len(interpolationOpen)+end+1:]
+		default:
+			sb.WriteByte('$')
+			str = str[1:]
+		}
+	}
+}
+
+// keepingEscapes returns true if the value being expanded keeps "$$" as written.
+func (in *interpolator) keepingEscapes() bool {
+	return in.keepEscapes != nil && len(in.stack) > 0 && in.keepEscapes(in.stack[len(in.stack)-1])
+}
+
+// expandReference expands a single reference expression, the part between "${" and "}".
+func (in *interpolator) expandReference(expr string) (string, *interpolationError) {
+	name, op, arg := splitReference(expr, func(name string) bool {
+		_, found := in.lookupReference(name)
+
+		return found
+	})
+
+	if name == "" {
+		return "", &interpolationError{msg: fmt.Sprintf("empty variable name in ${%s}", expr)}
+	}
+
+	ref, found := in.lookupReference(name)
+
+	var val string
+
+	if found {
+		var err *interpolationError
+
+		if val, err = in.resolve(ref); err != nil {
+			return "", err
+		}
+
+		if len(in.stack) > 0 {
+			current := in.stack[len(in.stack)-1]
+			in.refs[current] = append(append(in.refs[current], ref), in.refs[ref]...)
+		}
+	}
+
+	// For the ':' variants an empty value is treated the same as an unset one.
+	missing := !found || (strings.HasPrefix(op, ":") && val == "")
+
+	switch strings.TrimPrefix(op, ":") {
+	case "-":
+		if missing {
+			return in.expand(arg)
+		}
+	case "?":
+		if missing {
+			msg := arg
+			if msg == "" {
+				msg = "required variable is not set"
+			}
+
+			return "", &interpolationError{msg: name + ": " + msg}
+		}
+	}
+
+	return val, nil
+}
+
+// lookupReference returns the key that a reference to name resolves to, and false if it is not set.
+// A reference to the key being expanded, as in PORT=${PORT:-8080}, is not set, so that its default applies
+// rather than a cycle being reported. Cycles through other keys, such as a=${b} and b=${a}, are still reported.
+func (in *interpolator) lookupReference(name string) (string, bool) {
+	ref, found := in.lookupKey(name)
+	if found && len(in.stack) > 0 && in.stack[len(in.stack)-1] == ref {
+		return "", false
+	}
+
+	return ref, found
+}
+
+// lookupKey returns the key under which name is stored, trying it as written and then normalized.
+func (in *interpolator) lookupKey(name string) (string, bool) {
+	if in.has(name) {
+		return name, true
+	}
+
+	normalized := in.normalize(name)
+	if in.has(normalized) {
+		return normalized, true
+	}
+
+	return "", false
+}
+
+// has returns true if the key is in the values, or its value is held in a secret file.
+func (in *interpolator) has(key string) bool {
+	if _, ok := in.values[key]; ok {
+		return true
+	}
+
+	return in.secrets != nil && in.secrets.hasSecretFile(key)
+}
+
+// splitReference splits a reference expression into its name, operator and argument. The name is made of
+// key characters, as in dotenv files, which include '-'. A name followed by ":-" or ":?" is taken whole.
+// Otherwise a '-' may either belong to the name or start a default: the longest name that isSet reports
+// as set wins, and if none is, the shortest one does, as in a shell. So ${log-level} is the value of
+// log-level if it is set, and ${PORT-8080} is the value of PORT, or 8080.
+//
+// Example:
+//
+//	splitReference("PORT:-8080", isSet) // Output: "PORT", ":-", "8080"
+func splitReference(expr string, isSet func(name string) bool) (string, string, string) {
+	expr = strings.TrimLeft(expr, " \t")
+
+	end := 0
+	for end < len(expr) && isDotenvKeyChar(expr[end]) {
+		end++
+	}
+
+	op, arg, ok := splitOperator(expr[end:])
+	if !ok {
+		// A lone ':' or any other character is not an operator, so the whole expression is the name.
+		return strings.TrimSpace(expr), "", ""
+	}
+
+	name := expr[:end]
+	if strings.HasPrefix(op, ":") || isSet(name) {
+		return name, op, arg
+	}
+
+	// Try the names ending before each '-', from the longest, falling back to the shortest.
+	for i := end - 1; i > 0; i-- {
+		if expr[i] != '-' {
+			continue
+		}
+
+		name, op, arg = expr[:i], "-", expr[i+1:]
+
+		if isSet(name) {
+			break
+		}
+	}
+
+	return name, op, arg
+}
+
+// splitOperator splits the rest of a reference expression after its name into the operator and argument.
+// It returns false if the rest is neither empty nor starts with an operator.
+func splitOperator(rest string) (string, string, bool) {
+	rest = strings.TrimLeft(rest, " \t")
+	if rest == "" {
+		return "", "", true
+	}
+
+	for _, op := range []string{":-", ":?", "-", "?"} {
+		if strings.HasPrefix(rest, op) {
+			return op, rest[len(op):], true
+		}
+	}
+
+	return "", "", false
+}
+
+// findClosingBrace returns the index of the brace that closes a reference, taking nested references
+// in defaults into account. It returns -1 if the reference is not closed.
+func findClosingBrace(str string) int {
+	depth := 0
+
+	for i := 0; i < len(str); i++ {
+		switch {
+		case strings.HasPrefix(str[i:], interpolationOpen):
+			depth++
+			i++
+		case str[i] == interpolationClose && depth == 0:
+			return i
+		case str[i] == interpolationClose:
+			depth--
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,100 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+const jsonReaderName = "reader"
+
+// FromJSON reads a JSON file and adds its contents to the config map.
+// Nested objects are flattened into keys joined by the struct delimiter, so that
+// {"server": {"port": 8080}} becomes "server.port". Arrays are flattened into indexed keys,
+// e.g. "server.hosts.0", which populate slice fields element by element.
+// An array replaces the whole list of the same key set by the sources it takes precedence over.
+// Numbers and booleans keep their type, so that decode sets them without parsing their string form.
+func (b *Builder) FromJSON(file string) *Builder {
+	b.record(func(rebuilt *Builder) { rebuilt.FromJSON(file) }, file)
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		if IsLocal() {
+			b.addFailure(FieldError{Source: Origin{Source: SourceJSON, File: file}.String(), Cause: errors.Wrap(err, "read")})
+		}
+
+		return b
+	}
+
+	return b.appendJSON(file, bytes.NewReader(content))
+}
+
+// FromJSONReader reads a JSON document from the reader and adds its contents to the config map,
+// the same way FromJSON does. The document is kept in memory, so that a rebuilt builder reads it again.
+func (b *Builder) FromJSONReader(r io.Reader) *Builder {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		origin := Origin{Source: SourceJSON, File: jsonReaderName}
+		b.addFailure(FieldError{Source: origin.String(), Cause: errors.Wrap(err, "read")})
+
+		return b
+	}
+
+	b.record(func(rebuilt *Builder) { rebuilt.FromJSONReader(bytes.NewReader(content)) })
+
+	return b.appendJSON(jsonReaderName, bytes.NewReader(content))
+}
+
+// appendJSON decodes a JSON document and adds its flattened contents to the config map.
+// Syntax errors are always reported.
+func (b *Builder) appendJSON(name string, r io.Reader) *Builder {
+	origin := Origin{Source: SourceJSON, File: name}
+	values := make(map[string]string)
+	typed := make(map[string]any)
+	lists := make(map[string]bool)
+
+	if err := flattenJSONDocument(r, b.structDelimiter, values, typed, lists); err != nil {
+		b.addFailure(FieldError{Source: origin.String(), Cause: errors.Wrap(err, "parse")})
+
+		return b
+	}
+
+	b.replaceLists(lists, values, origin)
+	b.mergeValues(values, typed, origin, nil)
+
+	return b
+}
+
+// flattenJSONDocument decodes a single JSON document and flattens it into the values and typed maps,
+// and the keys of its arrays into the lists set. The root of the document must be an object.
+func flattenJSONDocument(
+	r io.Reader,
+	delimiter string,
+	values map[string]string,
+	typed map[string]any,
+	lists map[string]bool,
+) error {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+
+	var root any
+
+	if err := dec.Decode(&root); err != nil {
+		return errors.WithStack(err)
+	}
+
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return errors.New("unexpected data after the top-level object")
+	}
+
+	if _, ok := root.(map[string]any); !ok {
+		return errors.New("the root of the document must be an object")
+	}
+
+	flattenValue(root, "", delimiter, values, typed, lists)
+
+	return nil
+}
@@ -0,0 +1,156 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFlattenJSONDocument(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		content   string
+		want      map[string]string
+		wantTyped map[string]any
+		wantErr   bool
+	}{
+		{
+			name:      "When object is empty then the maps should be empty",
+			content:   `{}`,
+			want:      map[string]string{},
+			wantTyped: map[string]any{},
+			wantErr:   false,
+		},
+		{
+			name:      "When object has nested objects then keys should be joined by the delimiter",
+			content:   `{"server": {"host": "localhost", "TLS": {"enabled": true}}}`,
+			want:      map[string]string{"server.host": "localhost", "server.TLS.enabled": "true"},
+			wantTyped: map[string]any{"server.TLS.enabled": true},
+			wantErr:   false,
+		},
+		{
+			name:    "When object has arrays then elements should get indexed keys",
+			content: `{"ports": [80, 443], "servers": [{"host": "x"}]}`,
+			want: map[string]string{
+				"ports.0":        "80",
+				"ports.1":        "443",
+				"servers.0.host": "x",
+			},
+			wantTyped: map[string]any{"ports.0": json.Number("80"), "ports.1": json.Number("443")},
+			wantErr:   false,
+		},
+		{
+			name:      "When object has empty arrays then they should be kept as empty values",
+			content:   `{"tags": [], "server": {"hosts": []}}`,
+			want:      map[string]string{"tags": "", "server.hosts": ""},
+			wantTyped: map[string]any{},
+			wantErr:   false,
+		},
+		{
+			name:      "When object has null values then they should be skipped",
+			content:   `{"key1": null, "key2": ""}`,
+			want:      map[string]string{"key2": ""},
+			wantTyped: map[string]any{},
+			wantErr:   false,
+		},
+		{
+			name:    "When root is not an object then it should return an error",
+			content: `["a", "b"]`,
+			wantErr: true,
+		},
+		{
+			name:    "When document is malformed then it should return an error",
+			content: `{"key": `,
+			wantErr: true,
+		},
+		{
+			name:    "When document has trailing data then it should return an error",
+			content: `{"key": 1} {"key": 2}`,
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := make(map[string]string)
+			gotTyped := make(map[string]any)
+
+			err := flattenJSONDocument(strings.NewReader(test.content), ".", got, gotTyped, make(map[string]bool))
+			if (err != nil) != test.wantErr {
+				t.Errorf(failTestMessage("flattenJSONDocument", test.wantErr, err))
+			}
+
+			if test.wantErr {
+				return
+			}
+
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf(failTestMessage("flattenJSONDocument", test.want, got))
+			}
+
+			if !reflect.DeepEqual(gotTyped, test.wantTyped) {
+				t.Errorf(failTestMessage("flattenJSONDocument", test.wantTyped, gotTyped))
+			}
+		})
+	}
+}
+
+func TestFromJSONReader(t *testing.T) {
+	t.Parallel()
+
+	type server struct {
+		Host  string  `config:"host"`
+		Port  uint16  `config:"port"`
+		Ports []int   `config:"ports"`
+		Ratio float64 `config:"ratio"`
+	}
+
+	type target struct {
+		Server server   `config:"server"`
+		Debug  bool     `config:"debug"`
+		Flag   string   `config:"flag"`
+		Tags   []string `config:"tags" default:"a b"`
+	}
+
+	content := `{
+		"debug": true,
+		"flag": false,
+		"tags": [],
+		"server": {"host": "localhost", "port": 8080, "ports": [80, 443], "ratio": 0.5}
+	}`
+
+	var got target
+
+	if err := New().FromJSONReader(strings.NewReader(content)).MapTo(&got); err != nil {
+		t.Fatal(err)
+	}
+
+	want := target{
+		Server: server{Host: "localhost", Port: 8080, Ports: []int{80, 443}, Ratio: 0.5},
+		Debug:  true,
+		Flag:   "false",
+		Tags:   []string{},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf(failTestMessage("FromJSONReader", want, got))
+	}
+}
+
+func TestFromJSONReaderOverflow(t *testing.T) {
+	t.Parallel()
+
+	var got struct {
+		Port uint8 `config:"port"`
+	}
+
+	err := New().FromJSONReader(strings.NewReader(`{"port": 8080}`)).MapTo(&got)
+	if err == nil {
+		t.Errorf(failTestMessage("FromJSONReader", "an error", err))
+	}
+}
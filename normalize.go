@@ -0,0 +1,113 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// KeyNormalizer maps a key to the form under which it is stored and matched, so that keys written
+// differently by sources and by struct fields reach the same field.
+// Both the keys read from sources and the keys built from struct fields are normalized.
+// A normalizer must be idempotent and map keys piece by piece, so that the normalized form of a prefix
+// is a prefix of the normalized key.
+type KeyNormalizer func(key string) string
+
+// LowerCaseKeys is the default KeyNormalizer. It matches keys regardless of case, so that FIELD1 in the
+// environment matches a field called Field1, and database.host matches Database.Host.
+func LowerCaseKeys(key string) string {
+	return strings.ToLower(key)
+}
+
+// EnvStyleKeys is a KeyNormalizer that also matches env-style keys, in which '_' separates nested fields.
+// It lowercases keys and replaces '_' with '.', so that DATABASE_HOST, database.host and Database.Host
+// all match the nested field Database.Host, whatever the struct delimiter.
+// Use tags to match fields whose name spans several words, e.g. `config:"max_conns"` for MAX_CONNS.
+func EnvStyleKeys(key string) string {
+	return strings.ToLower(strings.ReplaceAll(key, "_", "."))
+}
+
+// normalizedValues holds the values of a source, keyed by normalized key.
+type normalizedValues struct {
+	values map[string]string
+	typed  map[string]any
+	lines  map[string]int
+	// collisions holds, for each normalized key that several keys of the source collapse to,
+	// those keys in sorted order.
+	collisions map[string][]string
+}
+
+// normalizeKey returns the normalized form of a key, using LowerCaseKeys if no normalizer is set.
+func (b *Builder) normalizeKey(key string) string {
+	if b.keyNormalizer == nil {
+		return LowerCaseKeys(key)
+	}
+
+	return b.keyNormalizer(key)
+}
+
+// keyDelimiter returns the struct delimiter as it appears in normalized keys.
+func (b *Builder) keyDelimiter() string {
+	return b.normalizeKey(b.structDelimiter)
+}
+
+// normalizeValues normalizes the keys of the values of a source, with their typed values and lines.
+// When several keys collapse to the same normalized key, the last of them in sorted order is kept,
+// and the collision is recorded, so that decode reports it for the field that uses the key.
+func (b *Builder) normalizeValues(
+	values map[string]string,
+	typed map[string]any,
+	lines map[string]int,
+) normalizedValues {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	normalized := normalizedValues{
+		values:     make(map[string]string, len(values)),
+		typed:      make(map[string]any, len(typed)),
+		lines:      make(map[string]int, len(lines)),
+		collisions: make(map[string][]string),
+	}
+
+	sourceKeys := make(map[string][]string, len(values))
+
+	for _, key := range keys {
+		normKey := b.normalizeKey(key)
+		sourceKeys[normKey] = append(sourceKeys[normKey], key)
+
+		normalized.values[normKey] = values[key]
+
+		delete(normalized.typed, normKey)
+
+		if val, ok := typed[key]; ok {
+			normalized.typed[normKey] = val
+		}
+
+		if line, ok := lines[key]; ok {
+			normalized.lines[normKey] = line
+		}
+	}
+
+	for normKey, keys := range sourceKeys {
+		if len(keys) > 1 {
+			normalized.collisions[normKey] = keys
+		}
+	}
+
+	return normalized
+}
+
+// collisionError returns the cause of the failure of a key that several keys of a source collapse to.
+func collisionError(keys []string) error {
+	quoted := make([]string, len(keys))
+	for i, key := range keys {
+		quoted[i] = strconv.Quote(key)
+	}
+
+	return fmt.Errorf("%w: %s", ErrKeyCollision, strings.Join(quoted, ", "))
+}
@@ -0,0 +1,174 @@
+package config
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestKeyNormalizers(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		normalizer KeyNormalizer
+		key        string
+		want       string
+	}{
+		{
+			name:       "When keys are lowercased then case should be ignored",
+			normalizer: LowerCaseKeys,
+			key:        "Database.Host",
+			want:       "database.host",
+		},
+		{
+			name:       "When keys are lowercased then '_' should be kept",
+			normalizer: LowerCaseKeys,
+			key:        "DATABASE_HOST",
+			want:       "database_host",
+		},
+		{
+			name:       "When keys are env-style then '_' should separate fields",
+			normalizer: EnvStyleKeys,
+			key:        "DATABASE_HOST",
+			want:       "database.host",
+		},
+		{
+			name:       "When keys are env-style then dotted keys should be kept",
+			normalizer: EnvStyleKeys,
+			key:        "Database.Host",
+			want:       "database.host",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := test.normalizer(test.key); got != test.want {
+				t.Errorf(failTestMessage("normalizer", test.want, got))
+			}
+		})
+	}
+}
+
+func TestDecodeNormalizedKeys(t *testing.T) {
+	t.Parallel()
+
+	type database struct {
+		Host string
+		Port int `config:"port"`
+	}
+
+	type target struct {
+		Name     string
+		Database database
+	}
+
+	tests := []struct {
+		name    string
+		opts    []Option
+		values  map[string]string
+		wantOut target
+	}{
+		{
+			name:    "When keys differ from field names only by case then they should match",
+			opts:    nil,
+			values:  map[string]string{"NAME": "app", "DATABASE.HOST": "localhost", "database.Port": "5432"},
+			wantOut: target{Name: "app", Database: database{Host: "localhost", Port: 5432}},
+		},
+		{
+			name:    "When keys are env-style then they should match nested fields",
+			opts:    []Option{WithKeyNormalizer(EnvStyleKeys)},
+			values:  map[string]string{"NAME": "app", "DATABASE_HOST": "localhost", "Database.Port": "5432"},
+			wantOut: target{Name: "app", Database: database{Host: "localhost", Port: 5432}},
+		},
+		{
+			name:    "When keys are env-style then they should match fields joined by another struct delimiter",
+			opts:    []Option{WithKeyNormalizer(EnvStyleKeys), WithStructDelimiter("_")},
+			values:  map[string]string{"name": "app", "DATABASE_HOST": "localhost", "database_port": "5432"},
+			wantOut: target{Name: "app", Database: database{Host: "localhost", Port: 5432}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			b := New(test.opts...)
+			b.mergeValues(test.values, nil, Origin{Source: SourceEnv}, nil)
+
+			var got target
+			if err := b.MapTo(&got); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, test.wantOut) {
+				t.Errorf(failTestMessage("MapTo", test.wantOut, got))
+			}
+		})
+	}
+}
+
+func TestDecodeKeyCollisions(t *testing.T) {
+	t.Parallel()
+
+	type target struct {
+		Host string `config:"database.host"`
+	}
+
+	tests := []struct {
+		name     string
+		merges   []map[string]string
+		wantErr  string
+		wantHost string
+	}{
+		{
+			name:   "When two keys of a source collapse to a used key then it should be reported",
+			merges: []map[string]string{{"DATABASE_HOST": "a", "database.host": "b"}},
+			wantErr: `database.host (Host) from env: ` +
+				`keys collapse to the same normalized key: "DATABASE_HOST", "database.host"`,
+		},
+		{
+			name:     "When two keys of a source collapse to an unused key then it should be ignored",
+			merges:   []map[string]string{{"database.host": "a", "HTTP_PROXY": "x", "http_proxy": "x"}},
+			wantHost: "a",
+		},
+		{
+			name:     "When a later source sets a collapsed key then the collision should be resolved",
+			merges:   []map[string]string{{"DATABASE_HOST": "a", "database.host": "b"}, {"database_host": "c"}},
+			wantHost: "c",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			b := New(WithKeyNormalizer(EnvStyleKeys))
+			for _, values := range test.merges {
+				b.mergeValues(values, nil, Origin{Source: SourceEnv}, nil)
+			}
+
+			var got target
+			err := b.MapTo(&got)
+
+			if test.wantErr == "" {
+				if err != nil || got.Host != test.wantHost {
+					t.Errorf(failTestMessage("MapTo", test.wantHost, err))
+				}
+
+				return
+			}
+
+			var decodeErr *DecodeError
+			if !errors.As(err, &decodeErr) || len(decodeErr.Fields) != 1 || !errors.Is(err, ErrKeyCollision) {
+				t.Fatalf(failTestMessage("MapTo", test.wantErr, err))
+			}
+
+			if got := decodeErr.Fields[0].Error(); got != test.wantErr {
+				t.Errorf(failTestMessage("MapTo", test.wantErr, got))
+			}
+		})
+	}
+}
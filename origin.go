@@ -0,0 +1,188 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Kinds of sources, as found in Origin.Source and used to rank sources with WithPrecedence.
+// Sources added with From are named by their Name method instead.
+const (
+	SourceEnv    = "env"
+	SourceDotenv = "dotenv"
+	SourceYAML   = "yaml"
+	SourceJSON   = "json"
+	SourceTOML   = "toml"
+)
+
+// Origin describes the source that set the value of a key.
+type Origin struct {
+	// Source is the kind of source, i.e. env, dotenv, yaml, json or toml, or the name of a Source added with From.
+	Source string
+	// File is the file, or reader, that the value was read from. It is empty for the environment.
+	File string
+	// Line is the line of the key in the file, or 0 if the source does not track lines, as for JSON and TOML.
+	Line int
+}
+
+// String returns the origin as "source file:line", e.g. "dotenv .env:3", leaving out the parts that are unknown.
+func (o Origin) String() string {
+	switch {
+	case o.File == "":
+		return o.Source
+	case o.Line == 0:
+		return o.Source + " " + o.File
+	default:
+		return fmt.Sprintf("%s %s:%d", o.Source, o.File, o.Line)
+	}
+}
+
+// Explanation describes the value of a key, the source that set it, and the sources it shadowed.
+type Explanation struct {
+	// Key is the key as stored in the config map, e.g. server.port.
+	Key string
+	// Value is the raw value of the key, before interpolation and conversion, or a placeholder if it is redacted.
+	Value string
+	// Origin is the source that set the value.
+	Origin Origin
+	// Shadowed lists the other sources that set the key, which Origin takes precedence over,
+	// from lowest to highest precedence.
+	Shadowed []Origin
+}
+
+// String returns the explanation on a single line.
+//
+// Example:
+//
+//	server.port=9090 from env, shadowing dotenv .env:3, yaml config.yaml:2
+func (e Explanation) String() string {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "%s=%s from %s", e.Key, e.Value, e.Origin)
+
+	for i, shadowed := range e.Shadowed {
+		if i == 0 {
+			sb.WriteString(", shadowing ")
+		} else {
+			sb.WriteString(", ")
+		}
+
+		sb.WriteString(shadowed.String())
+	}
+
+	return sb.String()
+}
+
+// Explain returns the value of a key along with the source that set it and the sources it shadowed.
+// The key is looked up as written, then normalized, like references in interpolated values.
+// It returns false if no source set the key.
+// The values of keys that were decoded into secret fields, or read from secret files, are redacted. Which fields
+// are secret is only known once a struct is decoded, so every value is redacted until MapTo or Sub is called,
+// and only the secret fields of the structs decoded so far are known afterwards.
+//
+// Example:
+//
+//	builder := config.New().FromFile(".env").FromEnv()
+//	_ = builder.MapTo(&cfg) // tells which values are secret
+//	if explanation, ok := builder.Explain("SERVER_PORT"); ok {
+//	  fmt.Println(explanation) // Output: server_port=9090 from env, shadowing dotenv .env:3
+//	}
+func (b *Builder) Explain(key string) (Explanation, bool) {
+	history, ok := b.origins[key]
+	if !ok {
+		key = b.normalizeKey(key)
+
+		if history, ok = b.origins[key]; !ok {
+			return Explanation{}, false
+		}
+	}
+
+	value := b.configMap[key]
+	if !b.decoded || b.isSecretKey(key) {
+		value = redactedValue
+	}
+
+	return Explanation{
+		Key:      key,
+		Value:    value,
+		Origin:   history[len(history)-1],
+		Shadowed: append([]Origin(nil), history[:len(history)-1]...),
+	}, true
+}
+
+// Origins returns the source that set the current value of every key in the config map.
+func (b *Builder) Origins() map[string]Origin {
+	origins := make(map[string]Origin, len(b.origins))
+
+	for key, history := range b.origins {
+		origins[key] = history[len(history)-1]
+	}
+
+	return origins
+}
+
+// recordOrigin records that the source at origin set the keys of values.
+// Lines holds the line of each key in the file, if the source tracks them.
+// The history of each key is kept from lowest to highest precedence, so that the last origin is the one
+// whose value is in the config map, even when a source is added after a source of higher precedence.
+func (b *Builder) recordOrigin(values map[string]string, origin Origin, lines map[string]int) {
+	if b.origins == nil {
+		b.origins = make(map[string][]Origin, len(values))
+	}
+
+	rank := b.rank(origin.Source)
+
+	for key := range values {
+		keyOrigin := origin
+		keyOrigin.Line = lines[key]
+
+		history := b.origins[key]
+		pos := len(history)
+
+		for pos > 0 && b.rank(history[pos-1].Source) > rank {
+			pos--
+		}
+
+		b.origins[key] = slices.Insert(history, pos, keyOrigin)
+	}
+}
+
+// outranked returns true if the current value of a key comes from a source of higher precedence than source.
+func (b *Builder) outranked(key string, source string) bool {
+	history, ok := b.origins[key]
+
+	return ok && b.rank(history[len(history)-1].Source) > b.rank(source)
+}
+
+// outranks returns true if the current value of key comes from a source of higher precedence than the current
+// value of other.
+func (b *Builder) outranks(key string, other string) bool {
+	history, ok := b.origins[key]
+	otherHistory, otherOk := b.origins[other]
+
+	return ok && otherOk && b.rank(history[len(history)-1].Source) > b.rank(otherHistory[len(otherHistory)-1].Source)
+}
+
+// rank returns the precedence of a kind of source, as set by WithPrecedence.
+// Sources that are not ranked share the lowest precedence.
+func (b *Builder) rank(source string) int {
+	return b.precedence[source]
+}
+
+// originOf returns the source that set the current value of a key, or an empty string if no source did.
+func (b *Builder) originOf(key string) string {
+	history, ok := b.origins[key]
+	if !ok {
+		return ""
+	}
+
+	return history[len(history)-1].String()
+}
+
+// isFromEnv returns true if the current value of a key comes from the process environment.
+func (b *Builder) isFromEnv(key string) bool {
+	history, ok := b.origins[key]
+
+	return ok && history[len(history)-1].Source == SourceEnv
+}
@@ -0,0 +1,173 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestOriginString(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		origin Origin
+		want   string
+	}{
+		{
+			name:   "When origin has no file then only the source should be shown",
+			origin: Origin{Source: SourceEnv},
+			want:   "env",
+		},
+		{
+			name:   "When origin has no line then the file should be shown",
+			origin: Origin{Source: SourceJSON, File: "config.json"},
+			want:   "json config.json",
+		},
+		{
+			name:   "When origin has a line then it should follow the file",
+			origin: Origin{Source: SourceDotenv, File: ".env", Line: 3},
+			want:   "dotenv .env:3",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := test.origin.String(); got != test.want {
+				t.Errorf(failTestMessage("String", test.want, got))
+			}
+		})
+	}
+}
+
+func TestExplain(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	dotenvFile := filepath.Join(dir, ".env")
+	yamlFile := filepath.Join(dir, "config.yaml")
+
+	if err := os.WriteFile(dotenvFile, []byte("HOST=localhost\nPORT=80\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(yamlFile, []byte("debug: true\nport: 90\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	builder := New().appendFile(dotenvFile, true).FromYAML(yamlFile)
+	builder.mergeValues(map[string]string{"port": "100"}, nil, Origin{Source: SourceEnv}, nil)
+
+	var target struct {
+		Host string `config:"host"`
+		Port int    `config:"port"`
+	}
+
+	if err := builder.MapTo(&target); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		key    string
+		want   Explanation
+		wantOk bool
+	}{
+		{
+			name: "When key was set once then nothing should be shadowed",
+			key:  "host",
+			want: Explanation{
+				Key:      "host",
+				Value:    "localhost",
+				Origin:   Origin{Source: SourceDotenv, File: dotenvFile, Line: 1},
+				Shadowed: nil,
+			},
+			wantOk: true,
+		},
+		{
+			name: "When key was overwritten then the earlier sources should be shadowed",
+			key:  "PORT",
+			want: Explanation{
+				Key:    "port",
+				Value:  "100",
+				Origin: Origin{Source: SourceEnv},
+				Shadowed: []Origin{
+					{Source: SourceDotenv, File: dotenvFile, Line: 2},
+					{Source: SourceYAML, File: yamlFile, Line: 2},
+				},
+			},
+			wantOk: true,
+		},
+		{
+			name:   "When key was never set then it should not be found",
+			key:    "missing",
+			want:   Explanation{},
+			wantOk: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, ok := builder.Explain(test.key)
+			if ok != test.wantOk {
+				t.Errorf(failTestMessage("Explain", test.wantOk, ok))
+			}
+
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf(failTestMessage("Explain", test.want, got))
+			}
+		})
+	}
+}
+
+func TestExplanationString(t *testing.T) {
+	t.Parallel()
+
+	explanation := Explanation{
+		Key:    "server.port",
+		Value:  "9090",
+		Origin: Origin{Source: SourceEnv},
+		Shadowed: []Origin{
+			{Source: SourceDotenv, File: ".env", Line: 3},
+			{Source: SourceYAML, File: "config.yaml", Line: 2},
+		},
+	}
+
+	want := "server.port=9090 from env, shadowing dotenv .env:3, yaml config.yaml:2"
+	if got := explanation.String(); got != want {
+		t.Errorf(failTestMessage("String", want, got))
+	}
+}
+
+func TestDecodeErrorOrigin(t *testing.T) {
+	t.Parallel()
+
+	file := filepath.Join(t.TempDir(), ".env")
+
+	if err := os.WriteFile(file, []byte("HOST=localhost\nPORT=eighty\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var target struct {
+		Host string `config:"host"`
+		Port int    `config:"port"`
+	}
+
+	err := New().appendFile(file, true).MapTo(&target)
+
+	var decodeErr *DecodeError
+	if !errors.As(err, &decodeErr) || len(decodeErr.Fields) != 1 {
+		t.Fatalf(failTestMessage("MapTo", "a single field error", err))
+	}
+
+	want := Origin{Source: SourceDotenv, File: file, Line: 2}.String()
+	if got := decodeErr.Fields[0].Source; got != want {
+		t.Errorf(failTestMessage("MapTo", want, got))
+	}
+}
@@ -0,0 +1,437 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecodeSecretFiles(t *testing.T) {
+	t.Parallel()
+
+	type database struct {
+		Password string `config:"password"`
+		Port     int    `config:"port" secret:"true"`
+		URL      string `config:"url"`
+	}
+
+	type target struct {
+		Token    string   `config:"token"`
+		Database database `config:"db"`
+		Hosts    []string `config:"hosts"`
+		Storage  *url.URL `config:"storage"`
+		Log      string   `config:"log"`
+		LogFile  string   `config:"log_file"`
+	}
+
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "token"), "s3cr3t\n")
+	writeFile(t, filepath.Join(dir, "password"), "p@ss\r\n")
+	writeFile(t, filepath.Join(dir, "port"), "5432\n")
+	writeFile(t, filepath.Join(dir, "hosts"), "a b\n")
+
+	tests := []struct {
+		name    string
+		opts    []Option
+		values  map[string]string
+		wantOut target
+	}{
+		{
+			name: "When a key has a _FILE twin then the file contents should be the value",
+			opts: nil,
+			values: map[string]string{
+				"TOKEN_FILE":       filepath.Join(dir, "token"),
+				"DB.PASSWORD_FILE": filepath.Join(dir, "password"),
+				"HOSTS_FILE":       filepath.Join(dir, "hosts"),
+			},
+			wantOut: target{Token: "s3cr3t", Database: database{Password: "p@ss"}, Hosts: []string{"a", "b"}},
+		},
+		{
+			name: "When a value uses the file scheme then the file contents should be the value",
+			opts: nil,
+			values: map[string]string{
+				"token":   "file://" + filepath.Join(dir, "token"),
+				"db.port": "file://" + filepath.Join(dir, "port"),
+			},
+			wantOut: target{Token: "s3cr3t", Database: database{Port: 5432}},
+		},
+		{
+			name: "When a reference names a key with a _FILE twin then the file contents should be expanded",
+			opts: nil,
+			values: map[string]string{
+				"DB.PASSWORD_FILE": filepath.Join(dir, "password"),
+				"DB.URL":           "pg://admin:${db.password}@localhost",
+			},
+			wantOut: target{Database: database{Password: "p@ss", URL: "pg://admin:p@ss@localhost"}},
+		},
+		{
+			name:    "When a reference names a key with the file scheme then the file contents should be expanded",
+			opts:    nil,
+			values:  map[string]string{"secret": "file://" + filepath.Join(dir, "token"), "token": "${secret}!"},
+			wantOut: target{Token: "s3cr3t!"},
+		},
+		{
+			name:    "When a field is not a string nor a secret then the file scheme should be kept as is",
+			opts:    nil,
+			values:  map[string]string{"storage": "file://" + dir},
+			wantOut: target{Storage: &url.URL{Scheme: "file", Path: dir}},
+		},
+		{
+			name:    "When an unused _FILE key names a missing file then it should be ignored",
+			opts:    nil,
+			values:  map[string]string{"ssl_cert_file": filepath.Join(dir, "missing"), "token": "plain"},
+			wantOut: target{Token: "plain"},
+		},
+		{
+			name:    "When a field decodes a _FILE key then the key should not name a secret file",
+			opts:    nil,
+			values:  map[string]string{"LOG_FILE": filepath.Join(dir, "token")},
+			wantOut: target{LogFile: filepath.Join(dir, "token")},
+		},
+		{
+			name:    "When the file scheme is interpolated then the expanded path should be read",
+			opts:    nil,
+			values:  map[string]string{"secrets": dir, "token": "file://${secrets}/token"},
+			wantOut: target{Token: "s3cr3t"},
+		},
+		{
+			name:    "When both the key and its _FILE twin are set then the key should win",
+			opts:    nil,
+			values:  map[string]string{"token": "plain", "token_file": filepath.Join(dir, "token")},
+			wantOut: target{Token: "plain"},
+		},
+		{
+			name:    "When keys are env-style then the _FILE twin should still match",
+			opts:    []Option{WithKeyNormalizer(EnvStyleKeys)},
+			values:  map[string]string{"DB_PASSWORD_FILE": filepath.Join(dir, "password")},
+			wantOut: target{Database: database{Password: "p@ss"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			b := New(test.opts...)
+			b.mergeValues(test.values, nil, Origin{Source: SourceEnv}, nil)
+
+			var got target
+			if err := b.MapTo(&got); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, test.wantOut) {
+				t.Errorf(failTestMessage("MapTo", test.wantOut, got))
+			}
+		})
+	}
+}
+
+func TestSecretFilesReadOnUse(t *testing.T) {
+	t.Parallel()
+
+	var target struct {
+		Token string `config:"token"`
+	}
+
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "token"), "s3cr3t\n")
+	writeFile(t, filepath.Join(dir, "cert"), "unrelated\n")
+
+	b := New()
+	b.mergeValues(map[string]string{
+		"TOKEN_FILE":    filepath.Join(dir, "token"),
+		"SSL_CERT_FILE": filepath.Join(dir, "cert"),
+		"CA":            "file://" + filepath.Join(dir, "cert"),
+	}, nil, Origin{Source: SourceEnv}, nil)
+
+	dec := b.newDecoding()
+	dec.decodeStruct(reflect.ValueOf(&target), "", "")
+
+	var got []string
+	for key := range dec.secretFiles {
+		got = append(got, key)
+	}
+
+	if want := []string{"token"}; !reflect.DeepEqual(got, want) {
+		t.Errorf(failTestMessage("decodeStruct", want, got))
+	}
+}
+
+func TestDecodeSecretFileErrors(t *testing.T) {
+	t.Parallel()
+
+	type target struct {
+		Port    int    `config:"port" secret:"true"`
+		Token   string `config:"token"`
+		Workers int    `config:"workers"`
+	}
+
+	dir := t.TempDir()
+	missing := filepath.Join(dir, "missing")
+	writeFile(t, filepath.Join(dir, "port"), "not-a-port-s3cr3t\n")
+
+	tests := []struct {
+		name      string
+		values    map[string]string
+		wantErrIs error
+		wantErr   string
+	}{
+		{
+			name:      "When a _FILE twin names a missing file then it should be reported",
+			values:    map[string]string{"TOKEN_FILE": missing},
+			wantErrIs: fs.ErrNotExist,
+			wantErr:   "token_file (Token) from env: read secret file: open " + missing,
+		},
+		{
+			name:      "When a file scheme names a missing file then it should be reported",
+			values:    map[string]string{"token": "file://" + missing},
+			wantErrIs: fs.ErrNotExist,
+			wantErr:   "token (Token) from env: read secret file: open " + missing,
+		},
+		{
+			name:      "When a reference names a missing secret file then it should be reported",
+			values:    map[string]string{"token": "Bearer ${api_key}", "API_KEY_FILE": missing},
+			wantErrIs: fs.ErrNotExist,
+			wantErr:   "token (Token) from env: interpolate: read secret file: open " + missing,
+		},
+		{
+			name:      "When a reference to a secret file fails to convert then its contents should be redacted",
+			values:    map[string]string{"API_KEY_FILE": filepath.Join(dir, "port"), "workers": "${api_key}"},
+			wantErrIs: strconv.ErrSyntax,
+			wantErr:   "workers (Workers) from env: invalid secret value [REDACTED]",
+		},
+		{
+			name:      "When a secret fails to convert then its contents should be redacted",
+			values:    map[string]string{"port": "file://" + filepath.Join(dir, "port")},
+			wantErrIs: strconv.ErrSyntax,
+			wantErr:   "port (Port) from env: invalid secret value [REDACTED]",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			b := New()
+			b.mergeValues(test.values, nil, Origin{Source: SourceEnv}, nil)
+
+			var got target
+			err := b.MapTo(&got)
+
+			var decodeErr *DecodeError
+			if !errors.As(err, &decodeErr) || len(decodeErr.Fields) != 1 || !errors.Is(err, test.wantErrIs) {
+				t.Fatalf(failTestMessage("MapTo", test.wantErr, err))
+			}
+
+			if got := decodeErr.Fields[0].Error(); !strings.HasPrefix(got, test.wantErr) {
+				t.Errorf(failTestMessage("MapTo", test.wantErr, got))
+			}
+
+			if strings.Contains(err.Error(), "s3cr3t") || strings.Contains(decodeErr.Fields[0].RawValue, "s3cr3t") {
+				t.Errorf(failTestMessage("MapTo", "no secret in the error", err))
+			}
+		})
+	}
+}
+
+func TestSecretFormatting(t *testing.T) {
+	t.Parallel()
+
+	type database struct {
+		Host     string
+		Password Secret[string]
+		Port     Secret[int]
+	}
+
+	db := database{Host: "localhost", Password: NewSecret("s3cr3t"), Port: NewSecret(5432)}
+
+	var logged bytes.Buffer
+	slog.New(slog.NewJSONHandler(&logged, nil)).Info("config", "db", db, "password", db.Password)
+
+	marshaled, err := json.Marshal(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		got  string
+	}{
+		{name: "When formatted with %v then the value should be redacted", got: fmt.Sprintf("%v", db)},
+		{name: "When formatted with %+v then the value should be redacted", got: fmt.Sprintf("%+v", db)},
+		{name: "When formatted with %#v then the value should be redacted", got: fmt.Sprintf("%#v", db)},
+		{name: "When formatted with %d then the value should be redacted", got: fmt.Sprintf("%d", db.Port)},
+		{name: "When converted with String then the value should be redacted", got: db.Password.String()},
+		{name: "When logged with slog then the value should be redacted", got: logged.String()},
+		{name: "When marshaled to JSON then the value should be redacted", got: string(marshaled)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			if strings.Contains(test.got, "s3cr3t") || strings.Contains(test.got, "5432") {
+				t.Errorf(failTestMessage("Secret", redactedValue, test.got))
+			}
+
+			if !strings.Contains(test.got, redactedValue) {
+				t.Errorf(failTestMessage("Secret", redactedValue, test.got))
+			}
+		})
+	}
+
+	if db.Password.Value() != "s3cr3t" || db.Port.Value() != 5432 {
+		t.Errorf(failTestMessage("Value", "s3cr3t and 5432", []any{db.Password.Value(), db.Port.Value()}))
+	}
+}
+
+func TestDecodeSecretFields(t *testing.T) {
+	t.Parallel()
+
+	type credentials struct {
+		User     string `config:"user"`
+		Password string `config:"password"`
+	}
+
+	type target struct {
+		Token    Secret[string]   `config:"token"`
+		Port     Secret[int]      `config:"port"`
+		Hosts    Secret[[]string] `config:"hosts"`
+		APIKey   string           `config:"api_key" secret:"true"`
+		Optional Secret[string]   `config:"optional" default:"fallback"`
+		Auth     credentials      `config:"auth,secret"`
+	}
+
+	values := map[string]string{
+		"token":         "s3cr3t",
+		"port":          "5432",
+		"hosts.0":       "a",
+		"hosts.1":       "b",
+		"api_key":       "k3y",
+		"auth.user":     "admin",
+		"auth.password": "p@ss",
+	}
+
+	b := New()
+	b.mergeValues(values, map[string]any{"port": int64(5432)}, Origin{Source: SourceEnv}, nil)
+
+	var got target
+	if err := b.MapTo(&got); err != nil {
+		t.Fatal(err)
+	}
+
+	want := target{
+		Token:    NewSecret("s3cr3t"),
+		Port:     NewSecret(5432),
+		Hosts:    NewSecret([]string{"a", "b"}),
+		APIKey:   "k3y",
+		Optional: NewSecret("fallback"),
+		Auth:     credentials{User: "admin", Password: "p@ss"},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf(failTestMessage("MapTo", want, got))
+	}
+
+	for _, key := range []string{"token", "api_key", "auth.password", "hosts.1"} {
+		if explanation, _ := b.Explain(key); explanation.Value != redactedValue {
+			t.Errorf(failTestMessage("Explain", redactedValue, explanation))
+		}
+	}
+}
+
+func TestExplainBeforeDecode(t *testing.T) {
+	t.Parallel()
+
+	var target struct {
+		Host     string `config:"host"`
+		Password string `config:"password" secret:"true"`
+	}
+
+	b := New().From(NewMapSource("m", map[string]string{"host": "localhost", "password": "hunter2"}))
+
+	for _, key := range []string{"host", "password"} {
+		if explanation, _ := b.Explain(key); explanation.Value != redactedValue {
+			t.Errorf(failTestMessage("Explain", redactedValue, explanation))
+		}
+	}
+
+	if err := b.MapTo(&target); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{"host": "localhost", "password": redactedValue}
+	for key, wantValue := range want {
+		if explanation, _ := b.Explain(key); explanation.Value != wantValue {
+			t.Errorf(failTestMessage("Explain", wantValue, explanation))
+		}
+	}
+}
+
+func TestDecodeSecretFieldErrors(t *testing.T) {
+	t.Parallel()
+
+	type target struct {
+		Port    Secret[int]   `config:"port"`
+		Timeout time.Duration `config:"timeout" secret:"true"`
+		Retries int           `config:"retries,secret,required"`
+		Limits  []int         `config:"limits,secret"`
+		Plain   int           `config:"plain"`
+		Backoff int           `config:"backoff"`
+		Workers []int         `config:"workers"`
+	}
+
+	values := map[string]string{
+		"port":     "s3cr3t-port",
+		"timeout":  "s3cr3t-timeout",
+		"limits.0": "s3cr3t-limit",
+		"plain":    "visible",
+		"backoff":  "x${port}",
+		"workers":  "1 ${inner}",
+		"inner":    "${limits.0}",
+	}
+
+	b := New()
+	b.mergeValues(values, nil, Origin{Source: SourceEnv}, nil)
+
+	var got target
+	err := b.MapTo(&got)
+
+	var decodeErr *DecodeError
+	if !errors.As(err, &decodeErr) {
+		t.Fatalf(failTestMessage("MapTo", "*DecodeError", err))
+	}
+
+	want := []string{
+		"backoff (Backoff) from env: invalid secret value [REDACTED]",
+		"limits.0 (Limits[0]) from env: invalid secret value [REDACTED]",
+		`plain (Plain) from env: strconv.ParseInt: parsing "visible": invalid syntax`,
+		"port (Port) from env: invalid secret value [REDACTED]",
+		"retries (Retries): missing required value",
+		"timeout (Timeout) from env: invalid secret value [REDACTED]",
+		"workers (Workers[1]) from env: invalid secret value [REDACTED]",
+	}
+
+	if got := fieldErrorStrings(decodeErr.Fields); !reflect.DeepEqual(got, want) {
+		t.Errorf(failTestMessage("MapTo", want, got))
+	}
+
+	if strings.Contains(err.Error(), "s3cr3t") {
+		t.Errorf(failTestMessage("MapTo", "no secret in the error", err))
+	}
+
+	if !errors.Is(err, strconv.ErrSyntax) {
+		t.Errorf(failTestMessage("MapTo", "the redacted causes to stay reachable", err))
+	}
+}
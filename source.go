@@ -0,0 +1,234 @@
+package config
+
+import (
+	"context"
+	"io/fs"
+	"maps"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// Source provides config values, such as the environment, a file, or a remote key-value store.
+// Add a source to a builder with From.
+//
+// Example:
+//
+//	type consulSource struct {
+//	  client *consul.KV
+//	  prefix string
+//	}
+//
+//	func (s consulSource) Name() string { return "consul" }
+//
+//	func (s consulSource) Load(ctx context.Context) (map[string]string, error) {
+//	  pairs, _, err := s.client.List(s.prefix, (&consul.QueryOptions{}).WithContext(ctx))
+//	  ...
+//	}
+//
+//	builder.From(consulSource{client: client, prefix: "myapp/"})
+type Source interface {
+	// Name identifies the source in the origins of its keys, e.g. in Explain and in errors,
+	// and is the name to rank it with WithPrecedence.
+	Name() string
+	// Load returns the values of the source. Keys are normalized by the builder, and may use the struct
+	// delimiter to set nested fields. Values are ignored if an error is returned.
+	Load(ctx context.Context) (map[string]string, error)
+}
+
+// sourceLoad is what a source of this package loads, with more detail than Source provides.
+type sourceLoad struct {
+	values map[string]string
+	origin Origin
+	// lines holds the line of each key in the source, for the sources that track lines.
+	lines map[string]int
+	// err prevented the source from being read.
+	err error
+	// failures are positioned in the source, such as syntax errors, and do not prevent the other values from loading.
+	failures []FieldError
+}
+
+// detailedSource is implemented by the sources of this package that know the lines of their keys,
+// or report several failures at once.
+type detailedSource interface {
+	Source
+	load(ctx context.Context) sourceLoad
+}
+
+// From reads the values of a source and adds them to the config map. A source that fails to load is reported
+// by the next decode, and the values of the other sources are still used.
+func (b *Builder) From(src Source) *Builder {
+	return b.FromContext(context.Background(), src)
+}
+
+// FromContext reads the values of a source with the context and adds them to the config map, the same way
+// From does. The context is also used when a Watcher reads the source again, so it should outlive the watcher.
+func (b *Builder) FromContext(ctx context.Context, src Source) *Builder {
+	b.record(func(rebuilt *Builder) { rebuilt.FromContext(ctx, src) }, sourceFiles(src)...)
+
+	return b.load(ctx, src, true)
+}
+
+// load reads the values of a source and adds them to the config map.
+// If includeErr is true, it also reports the error that prevented the source from being read.
+// Failures positioned in the source are always reported.
+func (b *Builder) load(ctx context.Context, src Source, includeErr bool) *Builder {
+	loaded := loadSource(ctx, src)
+
+	if includeErr && loaded.err != nil {
+		b.addFailure(FieldError{Source: loaded.origin.String(), Cause: loaded.err})
+	}
+
+	for _, failure := range loaded.failures {
+		b.addFailure(failure)
+	}
+
+	b.mergeValues(loaded.values, nil, loaded.origin, loaded.lines)
+
+	return b
+}
+
+// loadSource loads a source, with the details that the sources of this package provide.
+func loadSource(ctx context.Context, src Source) sourceLoad {
+	if detailed, ok := src.(detailedSource); ok {
+		return detailed.load(ctx)
+	}
+
+	loaded := sourceLoad{origin: Origin{Source: src.Name()}}
+
+	values, err := src.Load(ctx)
+	if err != nil {
+		loaded.err = errors.Wrap(err, "load")
+
+		return loaded
+	}
+
+	loaded.values = values
+
+	return loaded
+}
+
+// sourceFiles returns the files that a source reads, for a Watcher to poll.
+func sourceFiles(src Source) []string {
+	if file, ok := src.(FileSource); ok {
+		return []string{file.Path}
+	}
+
+	return nil
+}
+
+// MapSource is a Source that provides a fixed set of values, e.g. in tests.
+type MapSource struct {
+	name   string
+	values map[string]string
+}
+
+// NewMapSource creates a source named name that provides a copy of values.
+//
+// Example:
+//
+//	builder.From(config.NewMapSource("defaults", map[string]string{"server.port": "8080"}))
+func NewMapSource(name string, values map[string]string) MapSource {
+	return MapSource{name: name, values: maps.Clone(values)}
+}
+
+// Name returns the name of the source.
+func (s MapSource) Name() string {
+	return s.name
+}
+
+// Load returns a copy of the values of the source.
+func (s MapSource) Load(_ context.Context) (map[string]string, error) {
+	return maps.Clone(s.values), nil
+}
+
+// EnvSource is the Source of environment variables, as read by FromEnv.
+type EnvSource struct {
+	// Prefix, if set, keeps only the variables that start with it, as set by WithEnvPrefix.
+	// The prefix is stripped, and '_' in the rest of the name is replaced with Delimiter.
+	Prefix string
+	// Delimiter is the struct delimiter that '_' is replaced with in prefixed variables. It defaults to ".".
+	Delimiter string
+}
+
+// Name returns SourceEnv.
+func (s EnvSource) Name() string {
+	return SourceEnv
+}
+
+// Load returns the environment variables.
+func (s EnvSource) Load(_ context.Context) (map[string]string, error) {
+	values := keyValsToMap(os.Environ())
+
+	if s.Prefix == "" {
+		return values, nil
+	}
+
+	delimiter := s.Delimiter
+	if delimiter == "" {
+		delimiter = defaultStructDelimiter
+	}
+
+	return scopeEnv(values, s.Prefix, delimiter), nil
+}
+
+// FileSource is the Source of a dotenv file, as read by FromFile and FromOptionalFile.
+type FileSource struct {
+	// Path is the path of the file.
+	Path string
+	// Optional, if true, makes a missing file provide no values instead of failing.
+	Optional bool
+}
+
+// Name returns SourceDotenv.
+func (s FileSource) Name() string {
+	return SourceDotenv
+}
+
+// Load returns the values of the file. It fails on the first syntax error.
+func (s FileSource) Load(ctx context.Context) (map[string]string, error) {
+	loaded := s.load(ctx)
+
+	if loaded.err != nil {
+		return nil, loaded.err
+	}
+
+	if len(loaded.failures) > 0 {
+		return nil, loaded.failures[0]
+	}
+
+	return loaded.values, nil
+}
+
+// load reads the file, with the line of each key, and reports each syntax error positioned as file:line.
+func (s FileSource) load(ctx context.Context) sourceLoad {
+	loaded := sourceLoad{origin: Origin{Source: SourceDotenv, File: s.Path}}
+
+	if err := ctx.Err(); err != nil {
+		loaded.err = errors.Wrap(err, "read")
+
+		return loaded
+	}
+
+	content, err := os.ReadFile(s.Path)
+	if err != nil {
+		if !s.Optional || !errors.Is(err, fs.ErrNotExist) {
+			loaded.err = errors.Wrap(err, "read")
+		}
+
+		return loaded
+	}
+
+	values, lines, syntaxErrs := parseDotenv(s.Path, string(content))
+
+	for _, syntaxErr := range syntaxErrs {
+		loaded.failures = append(loaded.failures, FieldError{
+			Source: Origin{Source: SourceDotenv, File: s.Path, Line: syntaxErr.line}.String(),
+			Cause:  errors.Errorf("parse: %s", syntaxErr.msg),
+		})
+	}
+
+	loaded.values, loaded.lines = values, lines
+
+	return loaded
+}
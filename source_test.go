@@ -0,0 +1,213 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// failingSource is a Source that always fails to load.
+type failingSource struct{}
+
+func (failingSource) Name() string { return "failing" }
+
+func (failingSource) Load(_ context.Context) (map[string]string, error) {
+	return map[string]string{"port": "1"}, errors.New("connection refused")
+}
+
+func TestFrom(t *testing.T) {
+	t.Parallel()
+
+	type target struct {
+		Port int    `config:"port"`
+		Name string `config:"name"`
+	}
+
+	tests := []struct {
+		name       string
+		opts       []Option
+		sources    []Source
+		wantOut    target
+		wantOrigin Origin
+		wantErr    string
+	}{
+		{
+			name:       "When a map source is added then its values should be decoded",
+			opts:       nil,
+			sources:    []Source{NewMapSource("defaults", map[string]string{"PORT": "80", "name": "app"})},
+			wantOut:    target{Port: 80, Name: "app"},
+			wantOrigin: Origin{Source: "defaults"},
+		},
+		{
+			name: "When sources are added then the last one should win",
+			opts: nil,
+			sources: []Source{
+				NewMapSource("defaults", map[string]string{"port": "80"}),
+				NewMapSource("overrides", map[string]string{"port": "8080"}),
+			},
+			wantOut:    target{Port: 8080},
+			wantOrigin: Origin{Source: "overrides"},
+		},
+		{
+			name: "When sources are ranked by name then the highest should win",
+			opts: []Option{WithPrecedence("overrides", "defaults")},
+			sources: []Source{
+				NewMapSource("defaults", map[string]string{"port": "80"}),
+				NewMapSource("overrides", map[string]string{"port": "8080"}),
+			},
+			wantOut:    target{Port: 80},
+			wantOrigin: Origin{Source: "defaults"},
+		},
+		{
+			name:    "When a source fails to load then its values should be ignored and the error reported",
+			opts:    nil,
+			sources: []Source{failingSource{}},
+			wantErr: "failing: load: connection refused",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			b := New(test.opts...)
+			for _, src := range test.sources {
+				b.From(src)
+			}
+
+			var got target
+			err := b.MapTo(&got)
+
+			if test.wantErr != "" {
+				var decodeErr *DecodeError
+				if !errors.As(err, &decodeErr) || decodeErr.Fields[0].Error() != test.wantErr {
+					t.Errorf(failTestMessage("From", test.wantErr, err))
+				}
+
+				if got.Port != 0 {
+					t.Errorf(failTestMessage("From", 0, got.Port))
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, test.wantOut) {
+				t.Errorf(failTestMessage("From", test.wantOut, got))
+			}
+
+			if origin := b.Origins()["port"]; origin != test.wantOrigin {
+				t.Errorf(failTestMessage("Origins", test.wantOrigin, origin))
+			}
+		})
+	}
+}
+
+func TestMapSourceCopiesValues(t *testing.T) {
+	t.Parallel()
+
+	values := map[string]string{"port": "80"}
+	src := NewMapSource("defaults", values)
+	values["port"] = "8080"
+
+	loaded, err := src.Load(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded["port"] = "9090"
+
+	if again, _ := src.Load(context.Background()); again["port"] != "80" {
+		t.Errorf(failTestMessage("Load", "80", again["port"]))
+	}
+}
+
+func TestFileSourceLoad(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "valid.env")
+	invalid := filepath.Join(dir, "invalid.env")
+	missing := filepath.Join(dir, "missing.env")
+
+	writeFile(t, valid, "PORT=80\n")
+	writeFile(t, invalid, "PORT=80\nNAME\n")
+
+	tests := []struct {
+		name     string
+		src      FileSource
+		wantOut  map[string]string
+		wantFail bool
+	}{
+		{
+			name:    "When the file is valid then its values should be loaded",
+			src:     FileSource{Path: valid},
+			wantOut: map[string]string{"PORT": "80"},
+		},
+		{
+			name:     "When the file has a syntax error then it should fail",
+			src:      FileSource{Path: invalid},
+			wantFail: true,
+		},
+		{
+			name:     "When a required file is missing then it should fail",
+			src:      FileSource{Path: missing},
+			wantFail: true,
+		},
+		{
+			name:    "When an optional file is missing then it should load no values",
+			src:     FileSource{Path: missing, Optional: true},
+			wantOut: nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := test.src.Load(context.Background())
+
+			if (err != nil) != test.wantFail {
+				t.Fatalf(failTestMessage("Load", test.wantFail, err))
+			}
+
+			if !reflect.DeepEqual(got, test.wantOut) {
+				t.Errorf(failTestMessage("Load", test.wantOut, got))
+			}
+		})
+	}
+}
+
+func TestEnvSourceLoad(t *testing.T) {
+	t.Setenv("SOURCE_TEST_DB_HOST", "localhost")
+
+	got, err := EnvSource{Prefix: "SOURCE_TEST_"}.Load(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{"DB.HOST": "localhost"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf(failTestMessage("Load", want, got))
+	}
+}
+
+func TestFromWatchesFileSource(t *testing.T) {
+	t.Parallel()
+
+	file := filepath.Join(t.TempDir(), ".env")
+	b := New().From(FileSource{Path: file, Optional: true}).From(NewMapSource("defaults", nil))
+
+	if want := []string{file}; !reflect.DeepEqual(b.files, want) {
+		t.Errorf(failTestMessage("From", want, b.files))
+	}
+
+	if len(b.loaders) != 2 {
+		t.Errorf(failTestMessage("From", 2, len(b.loaders)))
+	}
+}
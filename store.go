@@ -0,0 +1,104 @@
+package config
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Store holds a decoded config behind an atomic pointer, so that goroutines can read it while it is
+// being replaced. Values are snapshots: a value returned by Load is never modified by the store,
+// and should not be modified by the caller either.
+//
+// Updates are serialized, and the callbacks registered with OnChange run after each update,
+// in the order they were registered, before the next update starts.
+//
+// Example:
+//
+//	store, err := config.LoadStore[AppConfig]()
+//	if err != nil {
+//	  return err
+//	}
+//
+//	store.OnChange(func(old, updated *AppConfig) {
+//	  log.Printf("config: log level changed from %s to %s", old.LogLevel, updated.LogLevel)
+//	})
+//
+//	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+//	  cfg := store.Load()
+//	  ...
+//	})
+type Store[T any] struct {
+	value     atomic.Pointer[T]
+	mu        sync.Mutex
+	callbacks []func(old, updated *T)
+}
+
+// NewStore creates a store holding value, which may be nil until the first update.
+func NewStore[T any](value *T) *Store[T] {
+	s := &Store[T]{}
+	s.value.Store(value)
+
+	return s
+}
+
+// Load returns the current value.
+func (s *Store[T]) Load() *T {
+	return s.value.Load()
+}
+
+// Swap replaces the current value with value, runs the callbacks, and returns the previous value.
+func (s *Store[T]) Swap(value *T) *T {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old := s.value.Swap(value)
+	s.notify(old, value)
+
+	return old
+}
+
+// CompareAndSwap replaces the current value with value, and runs the callbacks, only if the current value
+// is still old. It returns false if another update happened since old was loaded.
+func (s *Store[T]) CompareAndSwap(old *T, value *T) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.value.CompareAndSwap(old, value) {
+		return false
+	}
+
+	s.notify(old, value)
+
+	return true
+}
+
+// Reload decodes the config of the builder into a new value and swaps it in. If the config fails to decode,
+// the current value is kept and the error is returned. Both the initial load of LoadStore and the reloads
+// of a Watcher go through Reload.
+func (s *Store[T]) Reload(builder *Builder) (*T, error) {
+	value := new(T)
+
+	if err := builder.MapTo(value); err != nil {
+		return nil, err
+	}
+
+	s.Swap(value)
+
+	return value, nil
+}
+
+// OnChange registers fn to be called after every update, with the previous and the new value.
+// Callbacks run while updates are held off, so they must not update the store themselves.
+func (s *Store[T]) OnChange(fn func(old, updated *T)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.callbacks = append(s.callbacks, fn)
+}
+
+// notify runs the callbacks in order. It must be called with the lock held.
+func (s *Store[T]) notify(old *T, value *T) {
+	for _, fn := range s.callbacks {
+		fn(old, value)
+	}
+}
@@ -0,0 +1,190 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+type storedConfig struct {
+	Version int    `config:"version"`
+	Name    string `config:"name"`
+}
+
+func TestStoreSwap(t *testing.T) {
+	t.Parallel()
+
+	first := &storedConfig{Version: 1}
+	second := &storedConfig{Version: 2}
+
+	store := NewStore(first)
+
+	if old := store.Swap(second); old != first {
+		t.Errorf(failTestMessage("Swap", first, old))
+	}
+
+	if got := store.Load(); got != second {
+		t.Errorf(failTestMessage("Load", second, got))
+	}
+}
+
+func TestStoreCompareAndSwap(t *testing.T) {
+	t.Parallel()
+
+	first := &storedConfig{Version: 1}
+	second := &storedConfig{Version: 2}
+	third := &storedConfig{Version: 3}
+
+	store := NewStore(first)
+
+	var changes int
+
+	store.OnChange(func(_, _ *storedConfig) { changes++ })
+
+	if !store.CompareAndSwap(first, second) {
+		t.Errorf(failTestMessage("CompareAndSwap", true, false))
+	}
+
+	if store.CompareAndSwap(first, third) {
+		t.Errorf(failTestMessage("CompareAndSwap", "stale value to be rejected", true))
+	}
+
+	if got := store.Load(); got != second || changes != 1 {
+		t.Errorf(failTestMessage("CompareAndSwap", "one change to version 2", got))
+	}
+}
+
+func TestStoreOnChange(t *testing.T) {
+	t.Parallel()
+
+	store := NewStore(&storedConfig{Version: 1})
+
+	var calls []string
+
+	store.OnChange(func(old, updated *storedConfig) {
+		calls = append(calls, fmt.Sprintf("first %d->%d", old.Version, updated.Version))
+	})
+	store.OnChange(func(_, _ *storedConfig) {
+		calls = append(calls, "second")
+	})
+
+	store.Swap(&storedConfig{Version: 2})
+	store.Swap(&storedConfig{Version: 3})
+
+	want := []string{"first 1->2", "second", "first 2->3", "second"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf(failTestMessage("OnChange", want, calls))
+	}
+}
+
+func TestStoreReload(t *testing.T) {
+	t.Parallel()
+
+	store := NewStore[storedConfig](nil)
+
+	valid := New()
+	valid.mergeValues(map[string]string{"version": "1", "name": "app"}, nil, Origin{Source: SourceEnv}, nil)
+
+	value, err := store.Reload(valid)
+	if err != nil || store.Load() != value || value.Version != 1 {
+		t.Fatalf(failTestMessage("Reload", "version 1", err))
+	}
+
+	invalid := New()
+	invalid.mergeValues(map[string]string{"version": "two"}, nil, Origin{Source: SourceEnv}, nil)
+
+	if _, err := store.Reload(invalid); err == nil {
+		t.Errorf(failTestMessage("Reload", "an error", err))
+	}
+
+	if store.Load() != value {
+		t.Errorf(failTestMessage("Reload", "previous value to be kept", store.Load()))
+	}
+}
+
+// TestStoreConcurrency is meant to be run with the race detector.
+func TestStoreConcurrency(t *testing.T) {
+	t.Parallel()
+
+	const (
+		writers = 8
+		readers = 8
+		updates = 100
+	)
+
+	store := NewStore(&storedConfig{Version: 0, Name: "v0"})
+
+	var (
+		mu      sync.Mutex
+		changes int
+		gaps    int
+	)
+
+	store.OnChange(func(old, updated *storedConfig) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		changes++
+
+		if updated.Version != old.Version+1 {
+			gaps++
+		}
+	})
+
+	var wg sync.WaitGroup
+
+	stop := make(chan struct{})
+
+	for range readers {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					if snapshot := store.Load(); snapshot.Name == "" {
+						t.Errorf(failTestMessage("Load", "a complete snapshot", snapshot))
+					}
+				}
+			}
+		}()
+	}
+
+	var writersWg sync.WaitGroup
+
+	for range writers {
+		writersWg.Add(1)
+
+		go func() {
+			defer writersWg.Done()
+
+			for range updates {
+				for {
+					old := store.Load()
+					updated := &storedConfig{Version: old.Version + 1, Name: "next"}
+
+					if store.CompareAndSwap(old, updated) {
+						break
+					}
+				}
+			}
+		}()
+	}
+
+	writersWg.Wait()
+	close(stop)
+	wg.Wait()
+
+	if got := store.Load().Version; got != writers*updates {
+		t.Errorf(failTestMessage("CompareAndSwap", writers*updates, got))
+	}
+
+	if changes != writers*updates || gaps != 0 {
+		t.Errorf(failTestMessage("OnChange", writers*updates, changes))
+	}
+}
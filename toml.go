@@ -0,0 +1,76 @@
+package config
+
+import (
+	"os"
+
+	"github.com/BurntSushi/toml"
+	"github.com/pkg/errors"
+)
+
+// FromTOML reads a TOML file and adds its contents to the config map.
+// Tables are flattened into keys joined by the struct delimiter, so that the key "port" of the table
+// [database] becomes "database.port". Arrays and arrays of tables, such as [[upstreams]], are flattened
+// into indexed keys, e.g. "upstreams.0.host", which populate slice fields element by element.
+// An array replaces the whole list of the same key set by the sources it takes precedence over.
+// Integers, floats, booleans and datetimes keep their type, so that decode sets them without parsing
+// their string form.
+func (b *Builder) FromTOML(file string) *Builder {
+	b.record(func(rebuilt *Builder) { rebuilt.FromTOML(file) }, file)
+
+	if IsLocal() {
+		return b.appendTOML(file, true)
+	}
+
+	return b.appendTOML(file, false)
+}
+
+// appendTOML reads a TOML file and adds its flattened contents to the config map.
+// If includeErr is true, it will also report read errors.
+// Syntax errors are always reported.
+func (b *Builder) appendTOML(file string, includeErr bool) *Builder {
+	origin := Origin{Source: SourceTOML, File: file}
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		if includeErr {
+			b.addFailure(FieldError{Source: origin.String(), Cause: errors.Wrap(err, "read")})
+		}
+
+		return b
+	}
+
+	values := make(map[string]string)
+	typed := make(map[string]any)
+	lists := make(map[string]bool)
+
+	if err := flattenTOMLDocument(content, b.structDelimiter, values, typed, lists); err != nil {
+		b.addFailure(FieldError{Source: origin.String(), Cause: errors.Wrap(err, "parse")})
+
+		return b
+	}
+
+	b.replaceLists(lists, values, origin)
+	b.mergeValues(values, typed, origin, nil)
+
+	return b
+}
+
+// flattenTOMLDocument decodes a TOML document and flattens it into the values and typed maps,
+// and the keys of its arrays into the lists set.
+func flattenTOMLDocument(
+	content []byte,
+	delimiter string,
+	values map[string]string,
+	typed map[string]any,
+	lists map[string]bool,
+) error {
+	var root map[string]any
+
+	if err := toml.Unmarshal(content, &root); err != nil {
+		return errors.WithStack(err)
+	}
+
+	flattenValue(root, "", delimiter, values, typed, lists)
+
+	return nil
+}
@@ -0,0 +1,158 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFlattenTOMLDocument(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		content   string
+		want      map[string]string
+		wantTyped map[string]any
+		wantErr   bool
+	}{
+		{
+			name:      "When document is empty then the maps should be empty",
+			content:   "",
+			want:      map[string]string{},
+			wantTyped: map[string]any{},
+			wantErr:   false,
+		},
+		{
+			name:      "When document has tables then keys should be joined by the delimiter",
+			content:   "[database]\nhost = \"localhost\"\nport = 5432\n\n[database.Pool]\nratio = 0.5\n",
+			want:      map[string]string{"database.host": "localhost", "database.port": "5432", "database.Pool.ratio": "0.5"},
+			wantTyped: map[string]any{"database.port": int64(5432), "database.Pool.ratio": 0.5},
+			wantErr:   false,
+		},
+		{
+			name:    "When document has arrays of tables then elements should get indexed keys",
+			content: "tags = [\"a\", \"b\"]\n\n[[upstreams]]\nhost = \"x\"\n\n[[upstreams]]\nhost = \"y\"\n",
+			want: map[string]string{
+				"tags.0":           "a",
+				"tags.1":           "b",
+				"upstreams.0.host": "x",
+				"upstreams.1.host": "y",
+			},
+			wantTyped: map[string]any{},
+			wantErr:   false,
+		},
+		{
+			name:      "When document has empty arrays then they should be kept as empty values",
+			content:   "tags = []\n\n[server]\nhosts = []\n",
+			want:      map[string]string{"tags": "", "server.hosts": ""},
+			wantTyped: map[string]any{},
+			wantErr:   false,
+		},
+		{
+			name:      "When document has datetimes then they should keep their type",
+			content:   "created = 1979-05-27T07:32:00Z\n",
+			want:      map[string]string{"created": "1979-05-27T07:32:00Z"},
+			wantTyped: map[string]any{"created": time.Date(1979, 5, 27, 7, 32, 0, 0, time.UTC)},
+			wantErr:   false,
+		},
+		{
+			name:    "When document is malformed then it should return an error",
+			content: "[database\nhost = 1\n",
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := make(map[string]string)
+			gotTyped := make(map[string]any)
+
+			err := flattenTOMLDocument([]byte(test.content), ".", got, gotTyped, make(map[string]bool))
+			if (err != nil) != test.wantErr {
+				t.Errorf(failTestMessage("flattenTOMLDocument", test.wantErr, err))
+			}
+
+			if test.wantErr {
+				return
+			}
+
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf(failTestMessage("flattenTOMLDocument", test.want, got))
+			}
+
+			if !reflect.DeepEqual(gotTyped, test.wantTyped) {
+				t.Errorf(failTestMessage("flattenTOMLDocument", test.wantTyped, gotTyped))
+			}
+		})
+	}
+}
+
+func TestFromTOML(t *testing.T) {
+	t.Parallel()
+
+	type upstream struct {
+		Host   string  `config:"host"`
+		Port   int     `config:"port"`
+		Weight float32 `config:"weight"`
+	}
+
+	type target struct {
+		Database struct {
+			Host string `config:"host"`
+			Port uint16 `config:"port"`
+		} `config:"database"`
+		Upstreams []upstream `config:"upstreams"`
+		Created   time.Time  `config:"created"`
+		Start     time.Time  `config:"start" layout:"2006-01-02"`
+		End       time.Time  `config:"end" layout:"02/01/2006"`
+		Tags      []string   `config:"tags" default:"a b"`
+	}
+
+	file := filepath.Join(t.TempDir(), "config.toml")
+	content := `created = 1979-05-27T07:32:00Z
+start = 2024-01-02T00:00:00Z
+end = "31/12/2024"
+tags = []
+
+[database]
+host = "localhost"
+port = 5432
+
+[[upstreams]]
+host = "a"
+port = 80
+weight = 0.5
+
+[[upstreams]]
+host = "b"
+port = 443
+`
+
+	if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var got target
+
+	if err := New().FromTOML(file).MapTo(&got); err != nil {
+		t.Fatal(err)
+	}
+
+	var want target
+	want.Database.Host = "localhost"
+	want.Database.Port = 5432
+	want.Upstreams = []upstream{{Host: "a", Port: 80, Weight: 0.5}, {Host: "b", Port: 443}}
+	want.Created = time.Date(1979, 5, 27, 7, 32, 0, 0, time.UTC)
+	want.Start = time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	want.End = time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC)
+	want.Tags = []string{}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf(failTestMessage("FromTOML", want, got))
+	}
+}
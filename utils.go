@@ -1,8 +1,11 @@
 package config
 
 import (
+	"encoding/json"
 	"reflect"
+	"strconv"
 	"strings"
+	"time"
 )
 
 const (
@@ -10,6 +13,11 @@ const (
 	keyValueNumParts  = 2
 )
 
+const (
+	configTag          = "config"
+	configTagDelimiter = ","
+)
+
 // mergeMaps merges the source map into the destination map.
 // If a key exists in both maps, the value in the source map will overwrite the value in the destination map.
 func mergeMaps(dst, src map[string]string) {
@@ -21,7 +29,7 @@ func mergeMaps(dst, src map[string]string) {
 // keyValsToMap converts a slice of strings to a map.
 // Each string must be in the format "key=value".
 // If a string does not contain "=", it will be ignored.
-// The key will be converted to lowercase.
+// Keys are kept as written, and normalized when they are merged into the config map.
 func keyValsToMap(ss []string) map[string]string {
 	retMap := make(map[string]string)
 
@@ -33,7 +41,7 @@ func keyValsToMap(ss []string) map[string]string {
 		parts := strings.SplitN(s, keyValueDelimiter, keyValueNumParts)
 
 		if len(parts) == keyValueNumParts {
-			key := strings.ToLower(strings.TrimSpace(parts[0]))
+			key := strings.TrimSpace(parts[0])
 			val := strings.TrimSpace(parts[1])
 			retMap[key] = val
 		}
@@ -42,13 +50,27 @@ func keyValsToMap(ss []string) map[string]string {
 	return retMap
 }
 
+// fieldInfo describes a struct field reached by mapKeysToFields.
+type fieldInfo struct {
+	// value is the settable value of the field, or a pointer to it for pointer and interface fields.
+	value reflect.Value
+	// field is the struct field, which holds the tags of the field.
+	field reflect.StructField
+	// path is the Go path of the field from the root struct, e.g. Database.Host.
+	path string
+	// secret is true for secret fields, and for the fields of structs that are secret fields,
+	// whose values are redacted from errors and explanations.
+	secret bool
+}
+
 // mapKeysToFields recursively maps keys to fields in a struct.
 //
 // Params:
 //   - structPtr: A pointer to the struct to map keys to.
-//   - valMap: A map of keys to values.
+//   - valMap: A map of keys to fields.
 //   - prefix: The prefix to prepend to the keys.
 //   - structDelimiter: The delimiter to use when joining the prefix and field names.
+//   - d: The decoders registered with WithDecoder, whose types are mapped as a whole rather than field by field.
 //
 // Example:
 //
@@ -58,11 +80,29 @@ func keyValsToMap(ss []string) map[string]string {
 //
 //	config := Config{}
 //	structPtr := reflect.ValueOf(&config)
-//	valMap := make(map[string]reflect.Value)
-//	mapKeysToFields(structPtr, valMap, "app_", "_")
+//	valMap := make(map[string]fieldInfo)
+//	mapKeysToFields(structPtr, valMap, "app_", "_", nil)
 //
-//	fmt.Println(valMap) // Output: map[app_server_host:<value>]
-func mapKeysToFields(structPtr reflect.Value, valMap map[string]reflect.Value, prefix string, structDelimiter string) {
+//	fmt.Println(valMap) // Output: map[app_server_host:{<value> Host Host}]
+func mapKeysToFields(
+	structPtr reflect.Value,
+	valMap map[string]fieldInfo,
+	prefix string,
+	structDelimiter string,
+	d decoders,
+) {
+	mapKeysToFieldsWithPath(structPtr, valMap, prefix, "", structDelimiter, d)
+}
+
+// mapKeysToFieldsWithPath is mapKeysToFields for a struct nested at the given Go path.
+func mapKeysToFieldsWithPath(
+	structPtr reflect.Value,
+	valMap map[string]fieldInfo,
+	prefix string,
+	path string,
+	structDelimiter string,
+	d decoders,
+) {
 	structVal := structPtr.Elem()
 
 	for i := range structVal.NumField() {
@@ -70,21 +110,55 @@ func mapKeysToFields(structPtr reflect.Value, valMap map[string]reflect.Value, p
 		fieldPtr := structVal.Field(i).Addr()
 
 		key := getKey(field, prefix)
+		fieldPath := joinKey(path, field.Name, ".")
+		secret := isSecret(field)
 
 		switch field.Type.Kind() {
 		case reflect.Struct:
-			mapKeysToFields(fieldPtr, valMap, key+structDelimiter, structDelimiter)
+			if isLeafStruct(field.Type, d) {
+				valMap[key] = fieldInfo{value: fieldPtr.Elem(), field: field, path: fieldPath, secret: secret}
+
+				continue
+			}
+
+			mapKeysToFieldsWithPath(fieldPtr, valMap, key+structDelimiter, fieldPath, structDelimiter, d)
+
+			if secret {
+				markNestedSecret(valMap, key+structDelimiter)
+			}
 		case reflect.Pointer, reflect.Interface:
-			valMap[key] = fieldPtr
+			valMap[key] = fieldInfo{value: fieldPtr, field: field, path: fieldPath, secret: secret}
 		default:
-			valMap[key] = fieldPtr.Elem()
+			valMap[key] = fieldInfo{value: fieldPtr.Elem(), field: field, path: fieldPath, secret: secret}
 		}
 	}
 }
 
+// markNestedSecret marks the fields found under prefix, i.e. the fields of a struct that is a secret field,
+// as secret.
+func markNestedSecret(valMap map[string]fieldInfo, prefix string) {
+	for key, info := range valMap {
+		if strings.HasPrefix(key, prefix) {
+			info.secret = true
+			valMap[key] = info
+		}
+	}
+}
+
+// isLeafStruct returns true for struct types that are converted from a single value, such as time.Time,
+// net.IPNet, a Secret, netip.Addr or a type with a registered decoder, rather than mapped field by field.
+func isLeafStruct(t reflect.Type, d decoders) bool {
+	return t == timeType || t == ipNetType || isSecretType(t) || isTextType(t) || d.has(t)
+}
+
+// isStructType returns true for struct types that are mapped field by field.
+func isStructType(t reflect.Type, d decoders) bool {
+	return t.Kind() == reflect.Struct && !isLeafStruct(t, d)
+}
+
 // getKey returns the key for a field, based on its tag or name.
-// If a tag is present, it will be used as the key.
-// Otherwise, the field name will be used.
+// If a tag is present, its name, which is the part before any options such as ",required",
+// will be used as the key. Otherwise, the field name will be used.
 //
 // Params:
 //   - field: The field to get the key for.
@@ -104,9 +178,10 @@ func mapKeysToFields(structPtr reflect.Value, valMap map[string]reflect.Value, p
 func getKey(field reflect.StructField, prefix string) string {
 	name := field.Name
 
-	tag, exists := field.Tag.Lookup("config")
+	tag, exists := field.Tag.Lookup(configTag)
 
 	if exists {
+		tag, _, _ = strings.Cut(tag, configTagDelimiter)
 		tag = strings.TrimSpace(tag)
 		if tag != "" {
 			name = tag
@@ -116,6 +191,125 @@ func getKey(field reflect.StructField, prefix string) string {
 	return prefix + name
 }
 
+// hasTagOption returns true if the config tag of a field has the given option after its name.
+//
+// Example:
+//
+//	type Config struct {
+//	  Password string `config:"db_pass,required"`
+//	}
+//
+//	field := reflect.TypeOf(Config{}).Field(0)
+//	fmt.Println(hasTagOption(field, "required")) // Output: true
+func hasTagOption(field reflect.StructField, option string) bool {
+	tag := field.Tag.Get(configTag)
+
+	_, options, found := strings.Cut(tag, configTagDelimiter)
+	if !found {
+		return false
+	}
+
+	for _, opt := range strings.Split(options, configTagDelimiter) {
+		if strings.TrimSpace(opt) == option {
+			return true
+		}
+	}
+
+	return false
+}
+
+// joinKey joins a parent key and a child key with the delimiter. An empty parent returns the child as is.
+func joinKey(parent string, child string, delimiter string) string {
+	if parent == "" {
+		return child
+	}
+
+	return parent + delimiter + child
+}
+
+// flattenValue recursively flattens a value decoded by a structured source, such as JSON or TOML,
+// into the values map. Nested maps are joined by the delimiter and lists get indexed keys.
+// Keys are kept as written, and normalized when they are merged into the config map.
+// Numbers, booleans and datetimes are also added to the typed map. Nil values are skipped.
+// An empty list is kept as an empty value, so that it sets an empty slice rather than leaving the field
+// to its default. The keys of lists are added to the lists set.
+//
+// Example:
+//
+//	flattenValue(map[string]any{"server": map[string]any{"hosts": []any{"a", "b"}}}, "", ".", values, typed, lists)
+//	fmt.Println(values) // Output: map[server.hosts.0:a server.hosts.1:b]
+//	fmt.Println(lists)  // Output: map[server.hosts:true]
+func flattenValue(
+	val any,
+	key string,
+	delimiter string,
+	values map[string]string,
+	typed map[string]any,
+	lists map[string]bool,
+) {
+	switch typedVal := val.(type) {
+	case map[string]any:
+		for k, v := range typedVal {
+			flattenValue(v, joinKey(key, k, delimiter), delimiter, values, typed, lists)
+		}
+	case []map[string]any:
+		lists[key] = true
+
+		if len(typedVal) == 0 {
+			values[key] = ""
+		}
+
+		for i, elem := range typedVal {
+			flattenValue(elem, joinKey(key, strconv.Itoa(i), delimiter), delimiter, values, typed, lists)
+		}
+	case []any:
+		lists[key] = true
+
+		if len(typedVal) == 0 {
+			values[key] = ""
+		}
+
+		for i, elem := range typedVal {
+			flattenValue(elem, joinKey(key, strconv.Itoa(i), delimiter), delimiter, values, typed, lists)
+		}
+	case string:
+		values[key] = typedVal
+	case json.Number:
+		values[key] = typedVal.String()
+		typed[key] = typedVal
+	case int64:
+		values[key] = strconv.FormatInt(typedVal, 10)
+		typed[key] = typedVal
+	case float64:
+		values[key] = strconv.FormatFloat(typedVal, 'g', -1, 64)
+		typed[key] = typedVal
+	case bool:
+		values[key] = strconv.FormatBool(typedVal)
+		typed[key] = typedVal
+	case time.Time:
+		values[key] = typedVal.Format(time.RFC3339Nano)
+		typed[key] = typedVal
+	}
+}
+
+// parseIndex parses a list index made only of decimal digits, such as the "1" in servers.1.host.
+// Signs, spaces and other characters accepted by strconv.Atoi are rejected.
+func parseIndex(str string) (int, bool) {
+	if str == "" {
+		return 0, false
+	}
+
+	for _, c := range str {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+	}
+
+	index, err := strconv.Atoi(str)
+
+	return index, err == nil
+}
+
 // stringToSlice converts a string to a slice using the given delimiter.
 //
 // Params:
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func failTestMessage(funcName string, expected, got any) string {
@@ -94,9 +95,9 @@ func TestKeyValsToMap(t *testing.T) {
 			want: map[string]string{"key1": "value1", "key2": "value2"},
 		},
 		{
-			name: "When slice contains strings with different cases then the keys should be converted to lowercase",
+			name: "When slice contains strings with different cases then the keys should be kept as written",
 			ss:   []string{"Key1=value1", "KEY2=value2"},
-			want: map[string]string{"key1": "value1", "key2": "value2"},
+			want: map[string]string{"Key1": "value1", "KEY2": "value2"},
 		},
 	}
 
@@ -142,6 +143,13 @@ func TestMapKeysToFields(t *testing.T) {
 				"app_NestedStruct_field_3": reflect.ValueOf(true),     // NestedStruct.Field3 is tagged with config
 			},
 		},
+		{
+			name: "When struct has a time.Time field then it should be mapped as a single value",
+			structPtr: &struct {
+				Created time.Time `config:"created"`
+			}{Created: time.Date(1979, 5, 27, 0, 0, 0, 0, time.UTC)},
+			want: map[string]reflect.Value{"app_created": reflect.ValueOf(time.Date(1979, 5, 27, 0, 0, 0, 0, time.UTC))},
+		},
 		{
 			name:      "When struct has no fields then the map should be empty",
 			structPtr: &struct{}{},
@@ -188,19 +196,42 @@ func TestMapKeysToFields(t *testing.T) {
 		t.Run(test.name, func(t *testing.T) {
 			t.Parallel()
 
-			valMap := make(map[string]reflect.Value)
+			valMap := make(map[string]fieldInfo)
 
-			mapKeysToFields(reflect.ValueOf(test.structPtr), valMap, "app_", "_")
+			mapKeysToFields(reflect.ValueOf(test.structPtr), valMap, "app_", "_", nil)
 
 			for key, val := range valMap {
-				if !reflect.DeepEqual(val.Interface(), test.want[key].Interface()) {
-					t.Errorf(failTestMessage("mapKeysToFields", test.want[key], val))
+				if !reflect.DeepEqual(val.value.Interface(), test.want[key].Interface()) {
+					t.Errorf(failTestMessage("mapKeysToFields", test.want[key], val.value))
 				}
 			}
 		})
 	}
 }
 
+func TestMapKeysToFieldsPaths(t *testing.T) {
+	t.Parallel()
+
+	valMap := make(map[string]fieldInfo)
+
+	mapKeysToFields(reflect.ValueOf(&TestStruct{}), valMap, "", ".", nil)
+
+	want := map[string]string{
+		"field_1":              "Field1",
+		"Field2":               "Field2",
+		"NestedStruct.field_3": "NestedStruct.Field3",
+	}
+
+	got := make(map[string]string)
+	for key, field := range valMap {
+		got[key] = field.path
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf(failTestMessage("mapKeysToFields", want, got))
+	}
+}
+
 func TestGetKey(t *testing.T) {
 	t.Parallel()
 
@@ -222,6 +253,18 @@ func TestGetKey(t *testing.T) {
 			prefix: "app_",
 			want:   "app_Field1",
 		},
+		{
+			name:   "When field has a tag with options then only the name should be used as the key",
+			field:  reflect.StructField{Name: "Field1", Tag: `config:"tag1,required"`},
+			prefix: "app_",
+			want:   "app_tag1",
+		},
+		{
+			name:   "When field has a tag with options only then the field name should be used as the key",
+			field:  reflect.StructField{Name: "Field1", Tag: `config:",required"`},
+			prefix: "app_",
+			want:   "app_Field1",
+		},
 		{
 			name:   "When field has an empty tag then the field name should be used as the key",
 			field:  reflect.StructField{Name: "Field1", Tag: `config:""`},
@@ -241,6 +284,108 @@ func TestGetKey(t *testing.T) {
 	}
 }
 
+func TestJoinKey(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		parent string
+		child  string
+		want   string
+	}{
+		{
+			name:   "When parent is empty then the child should be returned",
+			parent: "",
+			child:  "port",
+			want:   "port",
+		},
+		{
+			name:   "When parent is set then it should be joined with the child",
+			parent: "server",
+			child:  "port",
+			want:   "server.port",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := joinKey(test.parent, test.child, "."); got != test.want {
+				t.Errorf(failTestMessage("joinKey", test.want, got))
+			}
+		})
+	}
+}
+
+func TestParseIndex(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		str    string
+		want   int
+		wantOk bool
+	}{
+		{name: "When string is a number then it should be parsed", str: "12", want: 12, wantOk: true},
+		{name: "When string is empty then it should fail", str: "", want: 0, wantOk: false},
+		{name: "When string has a sign then it should fail", str: "+1", want: 0, wantOk: false},
+		{name: "When string is negative then it should fail", str: "-1", want: 0, wantOk: false},
+		{name: "When string is not a number then it should fail", str: "host", want: 0, wantOk: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, ok := parseIndex(test.str)
+			if ok != test.wantOk || got != test.want {
+				t.Errorf(failTestMessage("parseIndex", test.want, got))
+			}
+		})
+	}
+}
+
+func TestHasTagOption(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		field  reflect.StructField
+		option string
+		want   bool
+	}{
+		{
+			name:   "When tag has the option then it should return true",
+			field:  reflect.StructField{Name: "Field1", Tag: `config:"tag1, secret ,required"`},
+			option: "required",
+			want:   true,
+		},
+		{
+			name:   "When tag does not have the option then it should return false",
+			field:  reflect.StructField{Name: "Field1", Tag: `config:"tag1,secret"`},
+			option: "required",
+			want:   false,
+		},
+		{
+			name:   "When the option is the tag name then it should return false",
+			field:  reflect.StructField{Name: "Field1", Tag: `config:"required"`},
+			option: "required",
+			want:   false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := hasTagOption(test.field, test.option); got != test.want {
+				t.Errorf(failTestMessage("hasTagOption", test.want, got))
+			}
+		})
+	}
+}
+
 func TestStringToSlice(t *testing.T) {
 	t.Parallel()
 
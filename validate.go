@@ -0,0 +1,515 @@
+package config
+
+import (
+	"cmp"
+	"fmt"
+	"net/url"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	validateTag          = "validate"
+	validateTagDelimiter = ","
+	regexpRule           = "regexp"
+	omitEmptyRule        = "omitempty"
+	minPort              = 1
+	maxPort              = 65535
+)
+
+// Validator is implemented by config structs that check invariants that tags cannot express,
+// such as a bound that depends on another field. After decoding, Validate is called on the target struct
+// and on every struct nested in it, through fields, pointers, slices and maps, and each error is reported
+// in the DecodeError along with the key and the path of the struct that returned it.
+//
+// Example:
+//
+//	type PoolConfig struct {
+//	  MinConns int `config:"min_conns"`
+//	  MaxConns int `config:"max_conns"`
+//	}
+//
+//	func (c PoolConfig) Validate() error {
+//	  if c.MaxConns < c.MinConns {
+//	    return errors.New("max_conns must be at least min_conns")
+//	  }
+//	  return nil
+//	}
+type Validator interface {
+	Validate() error
+}
+
+// rule is a validation rule of a `validate` tag, such as min=1, with its name and parameter.
+type rule struct {
+	name  string
+	param string
+}
+
+// parseRules splits a `validate` tag into its rules. A regexp rule takes the rest of the tag,
+// since its pattern may contain commas, so it must come last.
+//
+// Example:
+//
+//	parseRules("nonempty,regexp=^[a-z,]+$") // Output: []rule{{"nonempty", ""}, {"regexp", "^[a-z,]+$"}}
+func parseRules(tag string) []rule {
+	var rules []rule
+
+	for tag != "" {
+		var part string
+
+		part, tag, _ = strings.Cut(tag, validateTagDelimiter)
+
+		name, param, _ := strings.Cut(strings.TrimSpace(part), "=")
+
+		if name == regexpRule && tag != "" {
+			param += validateTagDelimiter + tag
+			tag = ""
+		}
+
+		rules = append(rules, rule{name: name, param: param})
+	}
+
+	return rules
+}
+
+// validateField checks the value of a field against the rules of its `validate` tag, and reports each
+// violation for the field. Fields without a value in the config map are checked too, against their default
+// or zero value, so that nonempty, min=1 or port fail for an absent field. Use omitempty for optional fields.
+// Nil pointers are the only values that are never checked.
+// Supported rules:
+//   - omitempty: skips the other rules when the value is the zero value, or an empty slice or map
+//   - min=N, max=N: bounds of numbers, of durations such as min=1s, and of the length of strings,
+//     slices and maps
+//   - len=N: exact length of strings, slices and maps
+//   - nonempty: strings, slices and maps must not be empty
+//   - oneof=a b c: strings and numbers must be one of the space-separated values
+//   - regexp=pattern: strings must match the pattern as a whole. It must be the last rule of the tag.
+//   - scheme=http https: URLs, as *url.URL or strings, must have one of the space-separated schemes
+//   - host: URLs, as *url.URL or strings, must have a host
+//   - port: numbers, and strings holding a number, must be between 1 and 65535
+//
+// Rules on the values of a slice, i.e. oneof, regexp, scheme, host and port, apply to each element.
+//
+// Example:
+//
+//	type Config struct {
+//	  Port     int           `config:"port" validate:"port"`
+//	  LogLevel string        `config:"log_level" validate:"omitempty,oneof=debug info warn error"`
+//	  Timeout  time.Duration `config:"timeout" validate:"min=1s,max=1m"`
+//	  Hosts    []string      `config:"hosts" validate:"nonempty,max=3,regexp=^[a-z.]+$"`
+//	}
+func (b *decoding) validateField(key string, field fieldInfo) {
+	tag := field.field.Tag.Get(validateTag)
+	if tag == "" {
+		return
+	}
+
+	value := field.value
+	if inner, ok := secretInner(value); ok {
+		value = inner.Elem()
+	}
+
+	value, ok := indirect(value)
+	if !ok {
+		return
+	}
+
+	rules := parseRules(tag)
+	if slices.Contains(rules, rule{name: omitEmptyRule}) && isEmpty(value) {
+		return
+	}
+
+	for _, r := range rules {
+		if !isElementRule(r) || value.Kind() != reflect.Slice {
+			b.addValidationFailure(key, field.path, checkRule(r, value))
+
+			continue
+		}
+
+		for i := range value.Len() {
+			if elem, ok := indirect(value.Index(i)); ok {
+				b.addValidationFailure(key, fmt.Sprintf("%s[%d]", field.path, i), checkRule(r, elem))
+			}
+		}
+	}
+}
+
+// addValidationFailure reports a failed rule for the field at path, if err is not nil.
+func (b *decoding) addValidationFailure(key string, path string, err error) {
+	if err != nil {
+		b.addFailure(FieldError{Key: key, FieldPath: path, RawValue: b.configMap[key], Cause: err})
+	}
+}
+
+// indirect returns the value that a value points to, through any number of pointers and interfaces,
+// and false if one of them is nil.
+func indirect(value reflect.Value) (reflect.Value, bool) {
+	for value.Kind() == reflect.Pointer || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return reflect.Value{}, false
+		}
+
+		value = value.Elem()
+	}
+
+	return value, true
+}
+
+// isEmpty returns true for a zero value, and for an empty slice or map.
+func isEmpty(value reflect.Value) bool {
+	if length, ok := lengthOf(value); ok {
+		return length == 0
+	}
+
+	return value.IsZero()
+}
+
+// isElementRule returns true for the rules that apply to each element of a slice rather than to the slice.
+func isElementRule(r rule) bool {
+	return slices.Contains([]string{"oneof", regexpRule, "scheme", "host", "port"}, r.name)
+}
+
+// checkRule returns an error wrapping ErrInvalidValue if the value breaks the rule,
+// or an error about the tag if the rule is unknown, malformed, or does not apply to the value.
+func checkRule(r rule, value reflect.Value) error {
+	switch r.name {
+	case omitEmptyRule:
+		if r.param != "" {
+			return invalidRule(r, value)
+		}
+
+		return nil
+	case "min", "max":
+		return checkBound(r, value)
+	case "len":
+		return checkLength(r, value)
+	case "nonempty":
+		return checkNonEmpty(r, value)
+	case "oneof":
+		return checkOneOf(r, value)
+	case regexpRule:
+		return checkRegexp(r, value)
+	case "scheme", "host":
+		return checkURL(r, value)
+	case "port":
+		return checkPort(r, value)
+	default:
+		return errors.Errorf("invalid %s tag: unknown rule %q", validateTag, r.name)
+	}
+}
+
+// invalidRule returns the error of a rule that is malformed or does not apply to the value.
+func invalidRule(r rule, value reflect.Value) error {
+	spec := r.name
+	if r.param != "" {
+		spec += "=" + r.param
+	}
+
+	return errors.Errorf("invalid %s tag: rule %q does not apply to %s", validateTag, spec, value.Type())
+}
+
+// checkBound checks a min or max rule against a number, a duration, or the length of a string, slice or map.
+func checkBound(r rule, value reflect.Value) error {
+	word := map[string]string{"min": "at least", "max": "at most"}[r.name]
+	violated := func(c int) bool { return (r.name == "min" && c < 0) || (r.name == "max" && c > 0) }
+
+	switch {
+	case isDuration(value.Type()):
+		bound, err := time.ParseDuration(r.param)
+		if err != nil {
+			return invalidRule(r, value)
+		}
+
+		if violated(cmp.Compare(time.Duration(value.Int()), bound)) {
+			return fmt.Errorf("%w: must be %s %s", ErrInvalidValue, word, bound)
+		}
+
+		return nil
+	case value.CanInt():
+		bound, err := strconv.ParseInt(r.param, 10, 64)
+		if err != nil {
+			return invalidRule(r, value)
+		}
+
+		if violated(cmp.Compare(value.Int(), bound)) {
+			return fmt.Errorf("%w: must be %s %d", ErrInvalidValue, word, bound)
+		}
+
+		return nil
+	case value.CanUint():
+		bound, err := strconv.ParseUint(r.param, 10, 64)
+		if err != nil {
+			return invalidRule(r, value)
+		}
+
+		if violated(cmp.Compare(value.Uint(), bound)) {
+			return fmt.Errorf("%w: must be %s %d", ErrInvalidValue, word, bound)
+		}
+
+		return nil
+	case value.CanFloat():
+		bound, err := strconv.ParseFloat(r.param, 64)
+		if err != nil {
+			return invalidRule(r, value)
+		}
+
+		if violated(cmp.Compare(value.Float(), bound)) {
+			return fmt.Errorf("%w: must be %s %s", ErrInvalidValue, word, r.param)
+		}
+
+		return nil
+	}
+
+	length, ok := lengthOf(value)
+	bound, err := strconv.Atoi(r.param)
+
+	if !ok || err != nil {
+		return invalidRule(r, value)
+	}
+
+	if violated(cmp.Compare(length, bound)) {
+		return fmt.Errorf("%w: length must be %s %d", ErrInvalidValue, word, bound)
+	}
+
+	return nil
+}
+
+// checkLength checks a len rule against the length of a string, slice or map.
+func checkLength(r rule, value reflect.Value) error {
+	length, ok := lengthOf(value)
+	bound, err := strconv.Atoi(r.param)
+
+	if !ok || err != nil {
+		return invalidRule(r, value)
+	}
+
+	if length != bound {
+		return fmt.Errorf("%w: length must be %d", ErrInvalidValue, bound)
+	}
+
+	return nil
+}
+
+// checkNonEmpty checks that a string, slice or map is not empty.
+func checkNonEmpty(r rule, value reflect.Value) error {
+	length, ok := lengthOf(value)
+	if !ok || r.param != "" {
+		return invalidRule(r, value)
+	}
+
+	if length == 0 {
+		return fmt.Errorf("%w: must not be empty", ErrInvalidValue)
+	}
+
+	return nil
+}
+
+// lengthOf returns the length of a string, in characters, or of a slice or map,
+// and false for any other value.
+func lengthOf(value reflect.Value) (int, bool) {
+	switch value.Kind() {
+	case reflect.String:
+		return utf8.RuneCountInString(value.String()), true
+	case reflect.Slice, reflect.Map:
+		return value.Len(), true
+	default:
+		return 0, false
+	}
+}
+
+// checkOneOf checks that a string or a number is one of the values of the rule.
+func checkOneOf(r rule, value reflect.Value) error {
+	allowed := strings.Fields(r.param)
+
+	var str string
+
+	switch {
+	case len(allowed) == 0:
+		return invalidRule(r, value)
+	case value.Kind() == reflect.String:
+		str = value.String()
+	case value.CanInt(), value.CanUint(), value.CanFloat():
+		str = fmt.Sprint(value.Interface())
+	default:
+		return invalidRule(r, value)
+	}
+
+	if !slices.Contains(allowed, str) {
+		return fmt.Errorf("%w: must be one of %s", ErrInvalidValue, strings.Join(allowed, ", "))
+	}
+
+	return nil
+}
+
+// checkRegexp checks that a string matches the pattern of the rule as a whole.
+func checkRegexp(r rule, value reflect.Value) error {
+	pattern, err := regexp.Compile("^(?:" + r.param + ")$")
+	if err != nil || value.Kind() != reflect.String {
+		return invalidRule(r, value)
+	}
+
+	if !pattern.MatchString(value.String()) {
+		return fmt.Errorf("%w: must match %q", ErrInvalidValue, r.param)
+	}
+
+	return nil
+}
+
+// checkURL checks a scheme or host rule against a url.URL or a string holding a URL.
+func checkURL(r rule, value reflect.Value) error {
+	var u *url.URL
+
+	switch {
+	case value.Type() == urlPtrType.Elem():
+		parsed, _ := value.Interface().(url.URL)
+		u = &parsed
+	case value.Kind() == reflect.String:
+		parsed, err := url.Parse(value.String())
+		if err != nil {
+			return fmt.Errorf("%w: must be a URL", ErrInvalidValue)
+		}
+
+		u = parsed
+	default:
+		return invalidRule(r, value)
+	}
+
+	if r.name == "host" {
+		if r.param != "" {
+			return invalidRule(r, value)
+		}
+
+		if u.Host == "" {
+			return fmt.Errorf("%w: must have a host", ErrInvalidValue)
+		}
+
+		return nil
+	}
+
+	schemes := strings.Fields(r.param)
+	if len(schemes) == 0 {
+		return invalidRule(r, value)
+	}
+
+	if !slices.Contains(schemes, u.Scheme) {
+		return fmt.Errorf("%w: scheme must be one of %s", ErrInvalidValue, strings.Join(schemes, ", "))
+	}
+
+	return nil
+}
+
+// checkPort checks that a number, or a string holding a number, is a valid port.
+func checkPort(r rule, value reflect.Value) error {
+	var (
+		port int64
+		err  error
+	)
+
+	switch {
+	case r.param != "":
+		return invalidRule(r, value)
+	case value.CanInt():
+		port = value.Int()
+	case value.CanUint():
+		port = int64(min(value.Uint(), maxPort+1))
+	case value.Kind() == reflect.String:
+		port, err = strconv.ParseInt(value.String(), 10, 64)
+	default:
+		return invalidRule(r, value)
+	}
+
+	if err != nil || port < minPort || port > maxPort {
+		return fmt.Errorf("%w: must be a port between %d and %d", ErrInvalidValue, minPort, maxPort)
+	}
+
+	return nil
+}
+
+// runValidators calls Validate on the struct that value holds, and on every struct nested in it, and reports
+// each error for the key and the path of the struct. Structs are reached through exported fields, pointers,
+// interfaces, secrets, and the elements of slices, arrays and maps.
+func (b *decoding) runValidators(value reflect.Value, key string, path string) {
+	switch value.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if !value.IsNil() {
+			b.runValidators(value.Elem(), key, path)
+		}
+	case reflect.Struct:
+		b.runStructValidators(value, key, path)
+	case reflect.Slice, reflect.Array:
+		if !mayHoldStruct(value.Type().Elem()) {
+			return
+		}
+
+		for i := range value.Len() {
+			b.runValidators(value.Index(i), joinKey(key, strconv.Itoa(i), b.keyDelimiter()), fmt.Sprintf("%s[%d]", path, i))
+		}
+	case reflect.Map:
+		if !mayHoldStruct(value.Type().Elem()) {
+			return
+		}
+
+		iter := value.MapRange()
+		for iter.Next() {
+			mapKey := fmt.Sprint(iter.Key().Interface())
+			b.runValidators(iter.Value(), joinKey(key, mapKey, b.keyDelimiter()), fmt.Sprintf("%s[%s]", path, mapKey))
+		}
+	default:
+	}
+}
+
+// runStructValidators calls Validate on the fields of a struct, then on the struct itself.
+func (b *decoding) runStructValidators(value reflect.Value, key string, path string) {
+	if inner, ok := secretInner(value); ok {
+		b.runValidators(inner, key, path)
+
+		return
+	}
+
+	if isLeafStruct(value.Type(), b.decoders) {
+		return
+	}
+
+	for i := range value.NumField() {
+		field := value.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		fieldKey := b.normalizeKey(getKey(field, ""))
+		b.runValidators(value.Field(i), joinKey(key, fieldKey, b.keyDelimiter()), joinKey(path, field.Name, "."))
+	}
+
+	var validator Validator
+
+	if value.CanAddr() {
+		validator, _ = value.Addr().Interface().(Validator)
+	} else {
+		validator, _ = value.Interface().(Validator)
+	}
+
+	if validator == nil {
+		return
+	}
+
+	if err := validator.Validate(); err != nil {
+		b.addFailure(FieldError{Key: key, FieldPath: path, Cause: err})
+	}
+}
+
+// mayHoldStruct returns false for types that cannot hold a struct, whose values runValidators can skip.
+func mayHoldStruct(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Struct, reflect.Pointer, reflect.Interface, reflect.Slice, reflect.Array, reflect.Map:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,412 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net/url"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestParseRules(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		tag  string
+		want []rule
+	}{
+		{
+			name: "When rules have parameters then they should be split",
+			tag:  "min=1, max=10",
+			want: []rule{{name: "min", param: "1"}, {name: "max", param: "10"}},
+		},
+		{
+			name: "When a rule has no parameter then its parameter should be empty",
+			tag:  "nonempty,oneof=a b",
+			want: []rule{{name: "nonempty"}, {name: "oneof", param: "a b"}},
+		},
+		{
+			name: "When a regexp has commas then it should take the rest of the tag",
+			tag:  "nonempty,regexp=^[a-z]{1,3}(,[a-z]+)*$",
+			want: []rule{{name: "nonempty"}, {name: "regexp", param: "^[a-z]{1,3}(,[a-z]+)*$"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := parseRules(test.tag); !reflect.DeepEqual(got, test.want) {
+				t.Errorf(failTestMessage("parseRules", test.want, got))
+			}
+		})
+	}
+}
+
+func TestDecodeValidation(t *testing.T) {
+	t.Parallel()
+
+	type target struct {
+		Port     int           `config:"port" validate:"port"`
+		Workers  uint          `config:"workers" validate:"min=1,max=8"`
+		Ratio    float64       `config:"ratio" validate:"min=0,max=1"`
+		Name     string        `config:"name" validate:"min=2,max=5"`
+		Code     string        `config:"code" validate:"len=3"`
+		LogLevel string        `config:"log_level" validate:"oneof=debug info warn error"`
+		Version  string        `config:"version" validate:"regexp=v[0-9]+(\\.[0-9]+){0,2}"`
+		Endpoint *url.URL      `config:"endpoint" validate:"scheme=http https,host"`
+		Callback string        `config:"callback" validate:"scheme=https"`
+		Hosts    []string      `config:"hosts" validate:"nonempty,oneof=a b"`
+		Timeout  time.Duration `config:"timeout" validate:"min=1s,max=1m" default:"30s"`
+		Optional *url.URL      `config:"optional" validate:"host"`
+		Secret   Secret[int]   `config:"secret" validate:"max=10"`
+	}
+
+	valid := map[string]string{
+		"port":      "8080",
+		"workers":   "4",
+		"ratio":     "0.5",
+		"name":      "app",
+		"code":      "abc",
+		"log_level": "info",
+		"version":   "v1.2",
+		"endpoint":  "https://example.com",
+		"callback":  "https://example.com/cb",
+		"hosts":     "a b",
+		"secret":    "3",
+	}
+
+	withValid := func(config map[string]string) map[string]string {
+		merged := maps.Clone(valid)
+		maps.Copy(merged, config)
+
+		return merged
+	}
+
+	tests := []struct {
+		name      string
+		config    map[string]string
+		wantErr   []string
+		wantErrIs error
+	}{
+		{
+			name:   "When all values follow their rules then there should be no error",
+			config: valid,
+		},
+		{
+			name: "When values break their rules then each violation should be reported",
+			config: map[string]string{
+				"port":      "99999",
+				"workers":   "0",
+				"ratio":     "1.5",
+				"name":      "application",
+				"code":      "ab",
+				"log_level": "verbose",
+				"version":   "1.2",
+				"endpoint":  "ftp:///files",
+				"callback":  "not a url\x7f",
+				"hosts":     "a c",
+				"timeout":   "2m",
+				"secret":    "42",
+			},
+			wantErr: []string{
+				"callback (Callback): invalid value: must be a URL",
+				"code (Code): invalid value: length must be 3",
+				"endpoint (Endpoint): invalid value: must have a host",
+				"endpoint (Endpoint): invalid value: scheme must be one of http, https",
+				"hosts (Hosts[1]): invalid value: must be one of a, b",
+				"log_level (LogLevel): invalid value: must be one of debug, info, warn, error",
+				"name (Name): invalid value: length must be at most 5",
+				"port (Port): invalid value: must be a port between 1 and 65535",
+				"ratio (Ratio): invalid value: must be at most 1",
+				"secret (Secret): invalid secret value [REDACTED]",
+				"timeout (Timeout): invalid value: must be at most 1m0s",
+				`version (Version): invalid value: must match "v[0-9]+(\\.[0-9]+){0,2}"`,
+				"workers (Workers): invalid value: must be at least 1",
+			},
+			wantErrIs: ErrInvalidValue,
+		},
+		{
+			name:   "When a slice is empty then nonempty should be reported",
+			config: withValid(map[string]string{"hosts": ""}),
+			wantErr: []string{
+				"hosts (Hosts): invalid value: must not be empty",
+			},
+			wantErrIs: ErrInvalidValue,
+		},
+		{
+			name:   "When a value fails to convert then it should not be validated",
+			config: withValid(map[string]string{"port": "http"}),
+			wantErr: []string{
+				`port (Port): strconv.ParseInt: parsing "http": invalid syntax`,
+			},
+			wantErrIs: strconv.ErrSyntax,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			b := New()
+			b.mergeValues(test.config, nil, Origin{}, nil)
+
+			var got target
+			err := b.MapTo(&got)
+
+			if len(test.wantErr) == 0 {
+				if err != nil {
+					t.Errorf(failTestMessage("MapTo", nil, err))
+				}
+
+				return
+			}
+
+			var decodeErr *DecodeError
+			if !errors.As(err, &decodeErr) {
+				t.Fatalf(failTestMessage("MapTo", test.wantErr, err))
+			}
+
+			if got := fieldErrorStrings(decodeErr.Fields); !reflect.DeepEqual(got, test.wantErr) {
+				t.Errorf(failTestMessage("MapTo", test.wantErr, got))
+			}
+
+			if !errors.Is(err, test.wantErrIs) {
+				t.Errorf(failTestMessage("MapTo", test.wantErrIs, err))
+			}
+		})
+	}
+}
+
+func TestDecodeValidationAbsentFields(t *testing.T) {
+	t.Parallel()
+
+	type target struct {
+		Hosts        []string `config:"hosts" validate:"nonempty"`
+		Port         int      `config:"port" validate:"port"`
+		Name         string   `config:"name" validate:"min=3"`
+		Labels       []string `config:"labels" validate:"omitempty,nonempty,max=2"`
+		Alias        string   `config:"alias" validate:"omitempty,min=3"`
+		AdminPort    int      `config:"admin_port" validate:"omitempty,port"`
+		DebugPort    *int     `config:"debug_port" validate:"port"`
+		MetricsPort  int      `config:"metrics_port" validate:"port" default:"9090"`
+		Replicas     int      `config:"replicas,required" validate:"min=1"`
+		ExplicitPort int      `config:"explicit_port" validate:"omitempty,port"`
+	}
+
+	b := New()
+	b.mergeValues(map[string]string{"explicit_port": "99999"}, nil, Origin{}, nil)
+
+	var got target
+
+	var decodeErr *DecodeError
+	if err := b.MapTo(&got); !errors.As(err, &decodeErr) {
+		t.Fatalf(failTestMessage("MapTo", "*DecodeError", err))
+	}
+
+	want := []string{
+		"explicit_port (ExplicitPort): invalid value: must be a port between 1 and 65535",
+		"hosts (Hosts): invalid value: must not be empty",
+		"name (Name): invalid value: length must be at least 3",
+		"port (Port): invalid value: must be a port between 1 and 65535",
+		"replicas (Replicas): missing required value",
+	}
+
+	if got := fieldErrorStrings(decodeErr.Fields); !reflect.DeepEqual(got, want) {
+		t.Errorf(failTestMessage("MapTo", want, got))
+	}
+}
+
+func TestDecodeInvalidValidateTag(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		target  any
+		wantErr string
+	}{
+		{
+			name: "When a rule is unknown then it should be reported",
+			target: &struct {
+				Port int `config:"port" validate:"positive"`
+			}{},
+			wantErr: `port (Port): invalid validate tag: unknown rule "positive"`,
+		},
+		{
+			name: "When a rule does not apply to the type then it should be reported",
+			target: &struct {
+				Port int `config:"port" validate:"regexp=[0-9]+"`
+			}{},
+			wantErr: `port (Port): invalid validate tag: rule "regexp=[0-9]+" does not apply to int`,
+		},
+		{
+			name: "When a bound is malformed then it should be reported",
+			target: &struct {
+				Port int `config:"port" validate:"max=lots"`
+			}{},
+			wantErr: `port (Port): invalid validate tag: rule "max=lots" does not apply to int`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			b := New()
+			b.mergeValues(map[string]string{"port": "80"}, nil, Origin{}, nil)
+
+			var decodeErr *DecodeError
+			if err := b.MapTo(test.target); !errors.As(err, &decodeErr) || len(decodeErr.Fields) != 1 {
+				t.Fatalf(failTestMessage("MapTo", test.wantErr, err))
+			}
+
+			if got := decodeErr.Fields[0].Error(); got != test.wantErr {
+				t.Errorf(failTestMessage("MapTo", test.wantErr, got))
+			}
+		})
+	}
+}
+
+type validatedPool struct {
+	MinConns int `config:"min_conns"`
+	MaxConns int `config:"max_conns"`
+}
+
+func (p validatedPool) Validate() error {
+	if p.MaxConns < p.MinConns {
+		return fmt.Errorf("max_conns %d is below min_conns %d", p.MaxConns, p.MinConns)
+	}
+
+	return nil
+}
+
+type validatedTLS struct {
+	Cert string `config:"cert"`
+	Key  string `config:"key"`
+}
+
+func (c *validatedTLS) Validate() error {
+	if (c.Cert == "") != (c.Key == "") {
+		return errors.New("cert and key must be set together")
+	}
+
+	return nil
+}
+
+type validatedServer struct {
+	Name string       `config:"name"`
+	TLS  validatedTLS `config:"tls"`
+}
+
+type validatedApp struct {
+	Pool     validatedPool            `config:"pool"`
+	TLS      validatedTLS             `config:"tls"`
+	Servers  []validatedServer        `config:"servers"`
+	Replicas map[string]validatedPool `config:"replicas"`
+	Name     string                   `config:"name"`
+}
+
+func (a *validatedApp) Validate() error {
+	if a.Name == "" {
+		return errors.New("name must be set")
+	}
+
+	return nil
+}
+
+func TestDecodeValidators(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		prefix  string
+		config  map[string]string
+		wantErr []string
+	}{
+		{
+			name: "When every struct is valid then there should be no error",
+			config: map[string]string{
+				"name":           "app",
+				"pool.min_conns": "1",
+				"pool.max_conns": "4",
+				"tls.cert":       "cert.pem",
+				"tls.key":        "key.pem",
+			},
+		},
+		{
+			name: "When nested structs are invalid then each should be reported with its path",
+			config: map[string]string{
+				"pool.min_conns":        "4",
+				"pool.max_conns":        "1",
+				"tls.cert":              "cert.pem",
+				"servers.0.name":        "a",
+				"servers.1.tls.key":     "key.pem",
+				"replicas.eu.min_conns": "2",
+				"replicas.us.min_conns": "0",
+				"replicas.us.max_conns": "1",
+				"servers.0.tls.cert":    "cert.pem",
+				"servers.0.tls.key":     "key.pem",
+				"replicas.eu.max_conns": "1",
+			},
+			wantErr: []string{
+				"config: name must be set",
+				"pool (Pool): max_conns 1 is below min_conns 4",
+				"replicas.eu (Replicas[eu]): max_conns 1 is below min_conns 2",
+				"servers.1.tls (Servers[1].TLS): cert and key must be set together",
+				"tls (TLS): cert and key must be set together",
+			},
+		},
+		{
+			name:   "When a prefix is used then keys should include it",
+			prefix: "app",
+			config: map[string]string{
+				"app.tls.key": "key.pem",
+			},
+			wantErr: []string{
+				"app: name must be set",
+				"app.tls (TLS): cert and key must be set together",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			b := New()
+			b.mergeValues(test.config, nil, Origin{}, nil)
+
+			var (
+				got validatedApp
+				err error
+			)
+
+			if test.prefix == "" {
+				err = b.MapTo(&got)
+			} else {
+				err = b.Sub(&got, test.prefix)
+			}
+
+			if len(test.wantErr) == 0 {
+				if err != nil {
+					t.Errorf(failTestMessage("MapTo", nil, err))
+				}
+
+				return
+			}
+
+			var decodeErr *DecodeError
+			if !errors.As(err, &decodeErr) {
+				t.Fatalf(failTestMessage("MapTo", test.wantErr, err))
+			}
+
+			if got := fieldErrorStrings(decodeErr.Fields); !reflect.DeepEqual(got, test.wantErr) {
+				t.Errorf(failTestMessage("MapTo", test.wantErr, got))
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package config
+
+import (
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Watcher polls the files read by a Builder, such as the files passed to FromFile, and decodes a new value
+// of T whenever one of them changes. On a change, all the sources of the builder are read again, in the
+// same order, and decoded into a fresh T, which is delivered to the subscribers.
+// If the new config fails to decode, the error is delivered instead and the previous value stays current.
+// The current value is held by a Store, whose OnChange callbacks also run on every successful reload.
+//
+// Polling only needs the standard library. A file is considered changed when its modification time or
+// size changes, or when it is created or removed.
+type Watcher[T any] struct {
+	builder     *Builder
+	store       *Store[T]
+	stamps      map[string]fileStamp
+	mu          sync.Mutex
+	subscribers []func(value *T, err error)
+	stop        chan struct{}
+	done        chan struct{}
+	closeOnce   sync.Once
+}
+
+// fileStamp is what a Watcher compares to find out whether a file changed.
+type fileStamp struct {
+	exists  bool
+	modTime time.Time
+	size    int64
+}
+
+// Watch decodes the config of the builder into a new T and starts polling the files of the builder
+// every interval. It returns an error if the initial config fails to decode, in which case nothing is watched.
+// Call Close to stop polling.
+//
+// Example:
+//
+//	watcher, err := config.Watch[AppConfig](builder.FromEnv().FromFile(".env"), 5*time.Second)
+//	if err != nil {
+//	  return err
+//	}
+//	defer watcher.Close()
+//
+//	watcher.Subscribe(func(cfg *AppConfig, err error) {
+//	  if err != nil {
+//	    log.Printf("config: keeping the previous config: %v", err)
+//	    return
+//	  }
+//	  server.SetLimits(cfg.Limits)
+//	})
+func Watch[T any](builder *Builder, interval time.Duration) (*Watcher[T], error) {
+	if interval <= 0 {
+		panic("config: watch interval must be positive")
+	}
+
+	w := &Watcher[T]{
+		builder: builder,
+		store:   NewStore[T](nil),
+		stamps:  stampFiles(builder.files),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	if _, err := w.store.Reload(builder); err != nil {
+		return nil, err
+	}
+
+	go w.poll(interval)
+
+	return w, nil
+}
+
+// Current returns the last value that decoded successfully.
+func (w *Watcher[T]) Current() *T {
+	return w.store.Load()
+}
+
+// Store returns the store that holds the current value.
+func (w *Watcher[T]) Store() *Store[T] {
+	return w.store
+}
+
+// Subscribe registers fn to be called after every reload, with either the new value or the error that
+// prevented it. Subscribers are called one after the other, from the polling goroutine.
+func (w *Watcher[T]) Subscribe(fn func(value *T, err error)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.subscribers = append(w.subscribers, fn)
+}
+
+// Close stops polling and waits for an ongoing reload to finish. It is safe to call Close more than once.
+func (w *Watcher[T]) Close() {
+	w.closeOnce.Do(func() {
+		close(w.stop)
+	})
+
+	<-w.done
+}
+
+// poll checks the files every interval until the watcher is closed.
+func (w *Watcher[T]) poll(interval time.Duration) {
+	defer close(w.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			w.check()
+		}
+	}
+}
+
+// check reloads the config if any file changed since the last check. It returns true if it reloaded.
+func (w *Watcher[T]) check() bool {
+	stamps := stampFiles(w.builder.files)
+
+	changed := false
+
+	for file, stamp := range stamps {
+		if w.stamps[file] != stamp {
+			changed = true
+		}
+	}
+
+	if !changed {
+		return false
+	}
+
+	w.stamps = stamps
+	w.reload()
+
+	return true
+}
+
+// reload reads the sources again and decodes them into a fresh T, then notifies the subscribers.
+// The current value is only replaced if the new config decodes successfully.
+func (w *Watcher[T]) reload() {
+	value, err := w.store.Reload(w.builder.rebuild())
+
+	w.mu.Lock()
+	subscribers := slices.Clone(w.subscribers)
+	w.mu.Unlock()
+
+	for _, fn := range subscribers {
+		fn(value, err)
+	}
+}
+
+// stampFiles returns the current stamp of each file.
+func stampFiles(files []string) map[string]fileStamp {
+	stamps := make(map[string]fileStamp, len(files))
+
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			stamps[file] = fileStamp{}
+
+			continue
+		}
+
+		stamps[file] = fileStamp{exists: true, modTime: info.ModTime(), size: info.Size()}
+	}
+
+	return stamps
+}
@@ -0,0 +1,147 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+type watchedConfig struct {
+	Port int    `config:"port"`
+	Name string `config:"name"`
+}
+
+// writeFile writes content to file, failing the test on error.
+func writeFile(t *testing.T, file string, content string) {
+	t.Helper()
+
+	if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWatcherCheck(t *testing.T) {
+	t.Parallel()
+
+	file := filepath.Join(t.TempDir(), ".env")
+	writeFile(t, file, "PORT=80\n")
+
+	watcher, err := Watch[watchedConfig](New().FromFile(file), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Close()
+
+	var (
+		gotValues []*watchedConfig
+		gotErrs   []error
+	)
+
+	watcher.Subscribe(func(value *watchedConfig, err error) {
+		gotValues = append(gotValues, value)
+		gotErrs = append(gotErrs, err)
+	})
+
+	if watcher.check() {
+		t.Errorf(failTestMessage("check", "no reload when nothing changed", "reload"))
+	}
+
+	writeFile(t, file, "PORT=9090\n")
+
+	if !watcher.check() || watcher.Current().Port != 9090 {
+		t.Errorf(failTestMessage("check", 9090, watcher.Current()))
+	}
+
+	writeFile(t, file, "PORT=invalid\n")
+
+	var decodeErr *DecodeError
+	if !watcher.check() || !errors.As(gotErrs[len(gotErrs)-1], &decodeErr) {
+		t.Errorf(failTestMessage("check", "*DecodeError", gotErrs))
+	}
+
+	if watcher.Current().Port != 9090 {
+		t.Errorf(failTestMessage("check", "previous value to stay current", watcher.Current()))
+	}
+
+	if len(gotValues) != 2 || gotValues[0].Port != 9090 || gotValues[1] != nil {
+		t.Errorf(failTestMessage("Subscribe", "9090 then nil", gotValues))
+	}
+}
+
+func TestWatcherOptionalFile(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	base := filepath.Join(dir, ".env")
+	override := filepath.Join(dir, ".env.local")
+	writeFile(t, base, "PORT=80\nNAME=base\n")
+
+	watcher, err := Watch[watchedConfig](New().FromFile(base).FromOptionalFile(override), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Close()
+
+	writeFile(t, override, "NAME=override\n")
+
+	if !watcher.check() || watcher.Current().Name != "override" {
+		t.Errorf(failTestMessage("check", "override", watcher.Current()))
+	}
+
+	if err := os.Remove(override); err != nil {
+		t.Fatal(err)
+	}
+
+	if !watcher.check() || watcher.Current().Name != "base" {
+		t.Errorf(failTestMessage("check", "base", watcher.Current()))
+	}
+}
+
+func TestWatcherPolling(t *testing.T) {
+	t.Parallel()
+
+	file := filepath.Join(t.TempDir(), ".env")
+	writeFile(t, file, "PORT=80\n")
+
+	watcher, err := Watch[watchedConfig](New().FromFile(file), 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded := make(chan *watchedConfig, 1)
+
+	var once sync.Once
+
+	watcher.Subscribe(func(value *watchedConfig, _ error) {
+		once.Do(func() { reloaded <- value })
+	})
+
+	writeFile(t, file, "PORT=9090\n")
+
+	select {
+	case value := <-reloaded:
+		if value == nil || value.Port != 9090 {
+			t.Errorf(failTestMessage("Subscribe", 9090, value))
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf(failTestMessage("Subscribe", "a reload", "timeout"))
+	}
+
+	watcher.Close()
+	watcher.Close()
+}
+
+func TestWatchInitialError(t *testing.T) {
+	t.Parallel()
+
+	file := filepath.Join(t.TempDir(), ".env")
+	writeFile(t, file, "PORT=invalid\n")
+
+	watcher, err := Watch[watchedConfig](New().FromFile(file), time.Hour)
+	if err == nil || watcher != nil {
+		t.Errorf(failTestMessage("Watch", "an error", err))
+	}
+}
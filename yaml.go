@@ -0,0 +1,190 @@
+package config
+
+import (
+	"os"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	yamlMergeKey = "<<"
+	yamlNullTag  = "!!null"
+)
+
+// FromYAML reads a YAML file and adds its contents to the config map.
+// Nested mappings are flattened into keys joined by the struct delimiter, so that
+// "server: {port: 8080}" becomes "server.port". Sequences are flattened into indexed keys,
+// e.g. "server.hosts.0", which populate slice fields element by element.
+// A sequence replaces the whole list of the same key set by the sources it takes precedence over.
+func (b *Builder) FromYAML(file string) *Builder {
+	b.record(func(rebuilt *Builder) { rebuilt.FromYAML(file) }, file)
+
+	if IsLocal() {
+		return b.appendYAML(file, true)
+	}
+
+	return b.appendYAML(file, false)
+}
+
+// appendYAML reads a YAML file and adds its flattened contents to the config map.
+// If includeErr is true, it will also report read errors.
+// Syntax errors are always reported.
+func (b *Builder) appendYAML(file string, includeErr bool) *Builder {
+	origin := Origin{Source: SourceYAML, File: file}
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		if includeErr {
+			b.addFailure(FieldError{Source: origin.String(), Cause: errors.Wrap(err, "read")})
+		}
+
+		return b
+	}
+
+	values := make(map[string]string)
+	lines := make(map[string]int)
+	lists := make(map[string]bool)
+
+	if err := flattenYAMLDocument(content, b.structDelimiter, values, lines, lists); err != nil {
+		b.addFailure(FieldError{Source: origin.String(), Cause: errors.Wrap(err, "parse")})
+
+		return b
+	}
+
+	b.replaceLists(lists, values, origin)
+	b.mergeValues(values, nil, origin, lines)
+
+	return b
+}
+
+// flattenYAMLDocument parses a YAML document and flattens it into the values map, the line of each
+// value into the lines map, and the keys of its sequences into the lists set.
+// An empty document is valid and adds nothing. The root of a non-empty document must be a mapping.
+func flattenYAMLDocument(
+	content []byte,
+	delimiter string,
+	values map[string]string,
+	lines map[string]int,
+	lists map[string]bool,
+) error {
+	var doc yaml.Node
+
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return errors.WithStack(err)
+	}
+
+	if len(doc.Content) == 0 {
+		return nil
+	}
+
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return errors.Errorf("line %d: the root of the document must be a mapping", root.Line)
+	}
+
+	return flattenYAML(root, "", delimiter, values, lines, lists)
+}
+
+// flattenYAML recursively flattens a YAML node into the values map.
+// Keys are kept as written, and normalized when they are merged into the config map.
+// Scalars keep the text they were written with, so that the regular converters parse them.
+// Null values are skipped. An empty sequence is kept as an empty value, so that it sets an empty slice
+// rather than leaving the field to its default. The keys of sequences are added to the lists set.
+//
+// Example:
+//
+//	server:
+//	  port: 8080
+//	  hosts: [a, b]
+//	  tags: []
+//
+//	// Output: map[server.port:8080 server.hosts.0:a server.hosts.1:b server.tags:]
+func flattenYAML(
+	node *yaml.Node,
+	key string,
+	delimiter string,
+	values map[string]string,
+	lines map[string]int,
+	lists map[string]bool,
+) error {
+	switch node.Kind {
+	case yaml.AliasNode:
+		return flattenYAML(node.Alias, key, delimiter, values, lines, lists)
+	case yaml.MappingNode:
+		return flattenYAMLMapping(node, key, delimiter, values, lines, lists)
+	case yaml.SequenceNode:
+		lists[key] = true
+
+		if len(node.Content) == 0 {
+			values[key] = ""
+			lines[key] = node.Line
+		}
+
+		for i, elem := range node.Content {
+			elemKey := joinKey(key, strconv.Itoa(i), delimiter)
+
+			if err := flattenYAML(elem, elemKey, delimiter, values, lines, lists); err != nil {
+				return err
+			}
+		}
+	case yaml.ScalarNode:
+		if node.Tag != yamlNullTag {
+			values[key] = node.Value
+			lines[key] = node.Line
+		}
+	case yaml.DocumentNode:
+		return errors.Errorf("line %d: unexpected nested document", node.Line)
+	}
+
+	return nil
+}
+
+// flattenYAMLMapping flattens the pairs of a mapping node. Merge keys ("<<") are applied first,
+// so that the keys written explicitly in the mapping take precedence over the merged ones.
+func flattenYAMLMapping(
+	node *yaml.Node,
+	key string,
+	delimiter string,
+	values map[string]string,
+	lines map[string]int,
+	lists map[string]bool,
+) error {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value != yamlMergeKey {
+			continue
+		}
+
+		merged := []*yaml.Node{node.Content[i+1]}
+		if node.Content[i+1].Kind == yaml.SequenceNode {
+			merged = node.Content[i+1].Content
+		}
+
+		for _, m := range merged {
+			if err := flattenYAML(m, key, delimiter, values, lines, lists); err != nil {
+				return err
+			}
+		}
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keyNode, valNode := node.Content[i], node.Content[i+1]
+
+		if keyNode.Value == yamlMergeKey {
+			continue
+		}
+
+		if keyNode.Kind != yaml.ScalarNode {
+			return errors.Errorf("line %d: mapping keys must be scalars", keyNode.Line)
+		}
+
+		childKey := joinKey(key, keyNode.Value, delimiter)
+
+		if err := flattenYAML(valNode, childKey, delimiter, values, lines, lists); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
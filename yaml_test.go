@@ -0,0 +1,225 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFlattenYAMLDocument(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		content string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name:    "When document is empty then the map should be empty",
+			content: "",
+			want:    map[string]string{},
+			wantErr: false,
+		},
+		{
+			name:    "When document has nested mappings then keys should be joined by the delimiter",
+			content: "server:\n  port: 8080\n  TLS:\n    enabled: true\n",
+			want:    map[string]string{"server.port": "8080", "server.TLS.enabled": "true"},
+			wantErr: false,
+		},
+		{
+			name:    "When document has sequences then elements should get indexed keys",
+			content: "hosts:\n  - a b\n  - c\nservers:\n  - host: x\n    port: 1\n",
+			want: map[string]string{
+				"hosts.0":        "a b",
+				"hosts.1":        "c",
+				"servers.0.host": "x",
+				"servers.0.port": "1",
+			},
+			wantErr: false,
+		},
+		{
+			name:    "When document has empty sequences then they should be kept as empty values",
+			content: "hosts: []\nservers:\n  -\n",
+			want:    map[string]string{"hosts": ""},
+			wantErr: false,
+		},
+		{
+			name:    "When document has null values then they should be skipped",
+			content: "key1: ~\nkey2: null\nkey3:\nkey4: ''\n",
+			want:    map[string]string{"key4": ""},
+			wantErr: false,
+		},
+		{
+			name:    "When document has anchors and merge keys then explicit keys should win",
+			content: "base: &base\n  host: localhost\n  port: 80\nprod:\n  <<: *base\n  port: 443\n",
+			want: map[string]string{
+				"base.host": "localhost",
+				"base.port": "80",
+				"prod.host": "localhost",
+				"prod.port": "443",
+			},
+			wantErr: false,
+		},
+		{
+			name:    "When root is not a mapping then it should return an error",
+			content: "- a\n- b\n",
+			want:    map[string]string{},
+			wantErr: true,
+		},
+		{
+			name:    "When document is malformed then it should return an error",
+			content: "key: [unclosed\n",
+			want:    map[string]string{},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := make(map[string]string)
+
+			err := flattenYAMLDocument([]byte(test.content), ".", got, make(map[string]int), make(map[string]bool))
+			if (err != nil) != test.wantErr {
+				t.Errorf(failTestMessage("flattenYAMLDocument", test.wantErr, err))
+			}
+
+			if !test.wantErr && !reflect.DeepEqual(got, test.want) {
+				t.Errorf(failTestMessage("flattenYAMLDocument", test.want, got))
+			}
+		})
+	}
+}
+
+func TestFromYAML(t *testing.T) {
+	t.Parallel()
+
+	type server struct {
+		Host  string   `config:"host"`
+		Port  int      `config:"port"`
+		Hosts []string `config:"hosts"`
+	}
+
+	type target struct {
+		Server  server   `config:"server"`
+		Debug   bool     `config:"debug"`
+		Tags    []string `config:"tags" default:"a b"`
+		Mirrors []server `config:"mirrors,required"`
+	}
+
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	content := "debug: true\nserver:\n  host: localhost\n  port: 8080\n  hosts:\n    - a b\n    - c\n" +
+		"tags: []\nmirrors: []\n"
+
+	if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var got target
+
+	if err := New().FromYAML(file).MapTo(&got); err != nil {
+		t.Fatal(err)
+	}
+
+	want := target{
+		Server:  server{Host: "localhost", Port: 8080, Hosts: []string{"a b", "c"}},
+		Debug:   true,
+		Tags:    []string{},
+		Mirrors: []server{},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf(failTestMessage("FromYAML", want, got))
+	}
+}
+
+func TestFromYAMLLayering(t *testing.T) {
+	t.Parallel()
+
+	type server struct {
+		Host string `config:"host"`
+		Port int    `config:"port"`
+	}
+
+	type target struct {
+		Hosts   []string `config:"hosts"`
+		Servers []server `config:"servers"`
+	}
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"base.yaml":     "hosts: [a, b, c]\nservers:\n  - {host: s1, port: 1}\n  - {host: s2, port: 2}\n",
+		"override.yaml": "hosts: [z]\nservers:\n  - {host: p1, port: 3}\n",
+		"empty.yaml":    "hosts: []\nservers: []\n",
+		"override.json": `{"hosts": ["j"], "servers": [{"host": "j1", "port": 4}]}`,
+	}
+
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		opts []Option
+		load func(b *Builder)
+		want target
+	}{
+		{
+			name: "When a later file sets a shorter list then it should replace the whole list",
+			opts: nil,
+			load: func(b *Builder) {
+				b.FromYAML(filepath.Join(dir, "base.yaml")).FromYAML(filepath.Join(dir, "override.yaml"))
+			},
+			want: target{Hosts: []string{"z"}, Servers: []server{{Host: "p1", Port: 3}}},
+		},
+		{
+			name: "When a later file sets empty lists then they should replace the lists",
+			opts: nil,
+			load: func(b *Builder) {
+				b.FromYAML(filepath.Join(dir, "base.yaml")).FromYAML(filepath.Join(dir, "empty.yaml"))
+			},
+			want: target{Hosts: []string{}, Servers: []server{}},
+		},
+		{
+			name: "When a list of higher precedence is added first then a later list should not merge into it",
+			opts: []Option{WithPrecedence(SourceYAML, SourceJSON)},
+			load: func(b *Builder) {
+				b.FromJSON(filepath.Join(dir, "override.json")).FromYAML(filepath.Join(dir, "base.yaml"))
+			},
+			want: target{Hosts: []string{"j"}, Servers: []server{{Host: "j1", Port: 4}}},
+		},
+		{
+			name: "When an element is set by a source of higher precedence then it should be kept",
+			opts: []Option{WithPrecedence(SourceYAML, "overrides")},
+			load: func(b *Builder) {
+				b.From(NewMapSource("overrides", map[string]string{"servers.0.host": "o1"})).
+					FromYAML(filepath.Join(dir, "base.yaml")).
+					FromYAML(filepath.Join(dir, "override.yaml"))
+			},
+			want: target{Hosts: []string{"z"}, Servers: []server{{Host: "o1", Port: 3}}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			b := New(test.opts...)
+			test.load(b)
+
+			var got target
+			if err := b.MapTo(&got); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf(failTestMessage("FromYAML", test.want, got))
+			}
+		})
+	}
+}